@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSep разделитель пути к архиву и пути внутри архива в положении
+// блока, например, release.tar.gz!src/main.go:12
+const ArchiveSep = "!"
+
+// archiveSuffixes расширения архивов, которые можно указать как директорию
+// поиска
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz"}
+
+// isArchive проверяет по расширению что файл является архивом
+func isArchive(file string) bool {
+	name := strings.ToLower(file)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenArchive открывает архив zip или tar, в том числе сжатый gzip или bzip2,
+// как файловую систему. Содержимое архива tar читается в память полностью.
+// Если файловая система реализует io.Closer, её нужно закрыть после
+// использования.
+func OpenArchive(file string) (fs.FS, error) {
+	name := strings.ToLower(file)
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(name, ".bz2") || strings.HasSuffix(name, ".tbz2") ||
+		strings.HasSuffix(name, ".tbz"):
+		r = bzip2.NewReader(f)
+	}
+	mfs, err := readTar(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return mfs, nil
+}
+
+// readTar читает файлы и директории архива tar в файловую систему в памяти.
+// Символические и жёсткие ссылки пропускаются.
+func readTar(r io.Reader) (*memFS, error) {
+	mfs := newMemFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return mfs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if name := path.Clean(strings.Trim(hdr.Name, "/")); fs.ValidPath(name) {
+				mfs.mkdir(name)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			mfs.addFile(hdr.Name, int64(len(data)), hdr.ModTime,
+				func() ([]byte, error) { return data, nil })
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// archiveFiles содержимое тестовых архивов, архив рабочей копии git
+var archiveFiles = map[string]string{
+	"rel/.git/HEAD":  "ref: refs/heads/master\n",
+	"rel/go.mod":     "module rel\n",
+	"rel/lib/lib.go": "package lib\n// TODO: in archive\n",
+}
+
+// writeTestArchive создаёт архив zip, tar или tar.gz с файлами archiveFiles
+func writeTestArchive(t *testing.T, file string) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	names := []string{"rel/.git/HEAD", "rel/go.mod", "rel/lib/lib.go"}
+	if strings.HasSuffix(file, ".zip") {
+		zw := zip.NewWriter(f)
+		for _, name := range names {
+			w, err := zw.Create(name)
+			if err == nil {
+				_, err = io.WriteString(w, archiveFiles[name])
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	var w io.Writer = f
+	if strings.HasSuffix(file, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		hdr := &tar.Header{Name: "./" + name, Mode: 0o644, ModTime: time.Unix(1e9, 0),
+			Size: int64(len(archiveFiles[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, archiveFiles[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test_Archive тестирует поиск в архивах zip, tar и tar.gz. Архив должен
+// открываться как файловая система, а положение блока указываться как путь
+// к архиву и путь внутри архива через разделитель «!». Архив рабочей копии
+// git с директорией .git ищется без git blame и без ошибок.
+func Test_Archive(t *testing.T) {
+	header := "архив:"
+	dir := t.TempDir()
+	for _, name := range []string{"rel.zip", "rel.tar", "rel.tar.gz"} {
+		file := filepath.Join(dir, name)
+		writeTestArchive(t, file)
+
+		afs, err := OpenArchive(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fstest.TestFS(afs, "rel/go.mod", "rel/lib/lib.go"); err != nil {
+			t.Errorf("%s %s: %v", header, name, err)
+		}
+		if c, ok := afs.(io.Closer); ok {
+			c.Close()
+		}
+
+		var stdout, stderr strings.Builder
+		code := run([]string{"-no-cache", "-strict", "-format", "json", file}, &stdout, &stderr)
+		if code != exitOK || stderr.Len() != 0 {
+			t.Fatalf("%s %s: код %d, %s", header, name, code, stderr.String())
+		}
+		want := `"file": "` + file + `!rel/lib/lib.go"`
+		if !strings.Contains(stdout.String(), want) ||
+			!strings.Contains(stdout.String(), `"project": "`+file+`!rel"`) {
+			t.Errorf("%s %s: нет %s в\n%s", header, name, want, stdout.String())
+		}
+	}
+
+	if code := run([]string{"-no-cache", "-watch", filepath.Join(dir, "rel.zip")},
+		io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("%s -watch: требуется код %d, имеется: %d", header, exitUsage, code)
+	}
+}
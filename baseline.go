@@ -0,0 +1,199 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultBaseline имя файла базового списка блоков по умолчанию
+const DefaultBaseline = ".todolist-baseline.json"
+
+// normalizeText возвращает текст блока без лишних пробелов: строки блока
+// объединяются, последовательности пробельных символов заменяются одним
+// пробелом.
+func normalizeText(lines []string) string {
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
+
+// Fingerprint возвращает отпечаток блока по проекту, файлу, тегу и тексту
+// блока. Номер строки в отпечаток не входит, поэтому перемещение блока внутри
+// файла отпечаток не меняет. Пути к проекту и файлу берутся относительно
+// корня поиска, поэтому отпечаток не зависит от того, как корень указан в
+// командной строке.
+func Fingerprint(td Todos) string {
+	sum := sha256.Sum256([]byte(relBase(td.root, td.project) + "\x00" +
+		relBase(td.root, td.file) + "\x00" + td.tag + "\x00" + normalizeText(td.lines)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// baselineEntry блок базового списка
+type baselineEntry struct {
+	Fingerprint string `json:"fingerprint"` // отпечаток блока
+	Project     string `json:"project"`     // путь к проекту от корня поиска
+	File        string `json:"file"`        // путь к файлу от корня поиска
+	Tag         string `json:"tag"`         // тег блока
+	Text        string `json:"text"`        // нормализованный текст блока
+}
+
+// Baseline базовый список блоков, с которым сравниваются результаты поиска
+type Baseline struct {
+	Version int             `json:"version"` // версия схемы
+	Todos   []baselineEntry `json:"todos"`   // блоки отсортированные по файлу
+}
+
+// NewBaseline возвращает базовый список для найденных блоков
+func NewBaseline(todos []Todos) Baseline {
+	bl := Baseline{Version: SchemaVersion, Todos: make([]baselineEntry, 0, len(todos))}
+	for _, td := range todos {
+		bl.Todos = append(bl.Todos, baselineEntry{Fingerprint(td),
+			relBase(td.root, td.project), relBase(td.root, td.file), td.tag,
+			normalizeText(td.lines)})
+	}
+	sort.SliceStable(bl.Todos, func(i, j int) bool {
+		a, b := bl.Todos[i], bl.Todos[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return bl
+}
+
+// LoadBaseline читает базовый список из файла
+func LoadBaseline(path string) (Baseline, error) {
+	var bl Baseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bl, err
+	}
+	if err := json.Unmarshal(data, &bl); err != nil {
+		return bl, fmt.Errorf("%s: %w", path, err)
+	}
+	if bl.Version != SchemaVersion {
+		return bl, fmt.Errorf("%s: неизвестная версия схемы %d", path, bl.Version)
+	}
+	return bl, nil
+}
+
+// Save записывает базовый список в файл
+func (bl Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(bl, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Check возвращает блоки, которых нет в базовом списке, и количество блоков
+// базового списка, которые не найдены. Одинаковые блоки учитываются по
+// количеству: если в базовом списке блок один, второй такой же блок новый.
+func (bl Baseline) Check(todos []Todos) ([]Todos, int) {
+	count := map[string]int{}
+	for _, entry := range bl.Todos {
+		count[entry.Fingerprint]++
+	}
+	added := make([]Todos, 0)
+	for _, td := range todos {
+		fp := Fingerprint(td)
+		if count[fp] > 0 {
+			count[fp]--
+			continue
+		}
+		added = append(added, td)
+	}
+	removed := 0
+	for _, n := range count {
+		removed += n
+	}
+	return added, removed
+}
+
+// runBaseline выполняет команду baseline: подкоманда write сохраняет
+// найденные блоки в файл базового списка, подкоманда check выводит блоки,
+// которых нет в базовом списке, и завершается с кодом exitError если такие
+// блоки есть.
+func runBaseline(args []string, stdout io.Writer, stderr io.Writer) int {
+	fset := flag.NewFlagSet("todolist baseline", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), `Использование: todolist baseline write|check [флаги] [директория ...]
+
+write сохраняет найденные блоки в файл базового списка, check выводит
+найденные блоки, которых нет в базовом списке. Блоки сравниваются по проекту,
+файлу, тегу и тексту без учёта номеров строк.
+
+Флаги:`)
+		fset.PrintDefaults()
+		fmt.Fprintln(fset.Output(), `
+Коды завершения: 0 новых блоков нет, 1 есть новые блоки или ошибка чтения
+или записи, 2 неверные аргументы.`)
+	}
+	sf := newScanFlags(fset)
+	sf.revFlag()
+	file := fset.String("file", DefaultBaseline, "файл базового списка")
+	format := fset.String("format", "quickfix", "формат вывода новых блоков: org, json, jsonl, sarif или quickfix")
+	if len(args) == 0 || (args[0] != "write" && args[0] != "check") {
+		fset.Usage()
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			return exitOK
+		}
+		return exitUsage
+	}
+	sub := args[0]
+	if err := fset.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	write, ok := Writers[*format]
+	if !ok {
+		fmt.Fprintln(stderr, "неизвестный формат вывода:", *format)
+		return exitUsage
+	}
+
+	roots, cfgs, code := sf.setup(fset.Args(), stderr)
+	if code != exitOK {
+		return code
+	}
+	defer sf.close(stderr)
+
+	todos, scanerrs := sf.scan(roots, cfgs, stderr)
+	WriteErrorSummary(stderr, scanerrs)
+	if sub == "write" {
+		if err := NewBaseline(todos).Save(*file); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	bl, err := LoadBaseline(*file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	added, removed := bl.Check(todos)
+	if err := write(stdout, added); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if removed > 0 {
+		fmt.Fprintf(stderr, "блоков базового списка не найдено: %d, обновите его командой baseline write\n", removed)
+	}
+	if len(added) > 0 {
+		fmt.Fprintf(stderr, "новых блоков: %d\n", len(added))
+		return exitError
+	}
+	return exitOK
+}
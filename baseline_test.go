@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test_Baseline тестирует сохранение и проверку базового списка. Перемещение
+// блока и изменение пробелов в его тексте не должны делать блок новым, а
+// добавленный блок должен выводиться командой baseline check с кодом 1.
+func Test_Baseline(t *testing.T) {
+	header := "базовый список:"
+	dir := t.TempDir()
+	file := filepath.Join(dir, "baseline.json")
+	src := filepath.Join(dir, "x.go")
+	writeFiles := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFiles("package x\n// TODO: keep   me\n// TODO: keep   me\n")
+	var stdout, stderr strings.Builder
+	if code := run([]string{"baseline", "write", "-no-cache", "-file", file, dir},
+		&stdout, &stderr); code != exitOK {
+		t.Fatalf("%s write: код %d, %s", header, code, stderr.String())
+	}
+
+	writeFiles("package x\n\nfunc f() {}\n\n// TODO: keep me\n// TODO:  keep me\n")
+	if code := run([]string{"baseline", "check", "-no-cache", "-file", file, dir},
+		&stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+		t.Errorf("%s перемещение: код %d, вывод %q", header, code, stdout.String())
+	}
+
+	writeFiles("package x\n// TODO: keep me\n// TODO: keep me\n// TODO: keep me\n// FIXME: new\n")
+	code := run([]string{"baseline", "check", "-no-cache", "-file", file, dir},
+		&stdout, &stderr)
+	if code != exitError {
+		t.Errorf("%s новые блоки: требуется код %d, имеется: %d", header, exitError, code)
+	}
+	got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	want := []string{"x.go:4:1: TODO: keep me", "x.go:5:1: FIXME: new"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	for i := range want {
+		if !strings.HasSuffix(got[i], want[i]) {
+			t.Errorf("%s %d: требуется: …%s, имеется: %s", header, i, want[i], got[i])
+		}
+	}
+
+	for _, args := range [][]string{{"baseline"}, {"baseline", "update"}} {
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%s %v: требуется код %d, имеется: %d", header, args, exitUsage, code)
+		}
+	}
+}
+
+// Test_BaselineRoots тестирует сравнение с базовым списком, записанным из
+// другого корня поиска: из «.», по абсолютному пути и из родительской
+// директории. Пути в отпечатках блоков указываются от корня поиска, поэтому
+// новых блоков быть не должно.
+func Test_BaselineRoots(t *testing.T) {
+	header := "базовый список из другого корня:"
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "repo")
+	file := filepath.Join(parent, "baseline.json")
+	for name, data := range map[string]string{"hello/go.mod": "module hello\n",
+		"hello/main.go": "package main\n// TODO: keep me\n"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	var stdout, stderr strings.Builder
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"baseline", "write", "-no-cache", "-file", file, "."},
+		&stdout, &stderr); code != exitOK {
+		t.Fatalf("%s write: код %d, %s", header, code, stderr.String())
+	}
+	check := func(root string) {
+		t.Helper()
+		if code := run([]string{"baseline", "check", "-no-cache", "-file", file, root},
+			&stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+			t.Errorf("%s %s: код %d, вывод %q", header, root, code, stdout.String())
+		}
+	}
+	check(dir)
+	if err := os.Chdir(parent); err != nil {
+		t.Fatal(err)
+	}
+	check("repo")
+}
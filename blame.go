@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Uncommitted значение коммита для строк, которые ещё не зафиксированы в
+// репозитории.
+const Uncommitted = "uncommitted"
+
+// Blame описывает коммит, который последним изменял строку файла
+type Blame struct {
+	commit string    // хэш коммита или Uncommitted
+	author string    // имя автора
+	email  string    // почта автора
+	time   time.Time // время коммита автора
+}
+
+// isCommitHash проверяет что строка является полным хэшем коммита SHA-1 или
+// SHA-256
+func isCommitHash(str string) bool {
+	if len(str) != 40 && len(str) != 64 {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		if !strings.ContainsRune("0123456789abcdef", rune(str[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeroCommit проверяет что хэш состоит из нулей, так git blame отмечает не
+// зафиксированные строки
+func isZeroCommit(commit string) bool {
+	return strings.Trim(commit, "0") == ""
+}
+
+// BlameFile возвращает для каждой строки файла коммит, который её последним
+// изменял. Используется локальный репозиторий проекта, для файлов, которых
+// нет в HEAD, и репозиториев без коммитов все строки отмечаются как не
+// зафиксированные. Возвращает ошибку
+// если git не установлен или завершился с другой ошибкой.
+func BlameFile(project string, file string) (map[int]Blame, error) {
+	rel, err := filepath.Rel(project, file)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "-C", project, "blame", "--line-porcelain", "--", rel)
+	// сообщения git без перевода, чтобы распознать отсутствие файла в HEAD
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		msg := strings.TrimSpace(string(exitErr.Stderr))
+		if strings.Contains(msg, "no such path") || strings.Contains(msg, "no such ref: HEAD") {
+			// файл не добавлен в репозиторий или коммитов ещё нет
+			return map[int]Blame{}, nil
+		}
+		return nil, NewScanError(file, fmt.Errorf("git blame: %s", msg))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame разбирает вывод git blame --line-porcelain
+func parseBlame(out []byte) map[int]Blame {
+	result := map[int]Blame{}
+	var cur Blame
+	line := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		str := scanner.Text()
+		switch {
+		case strings.HasPrefix(str, "\t"):
+			result[line] = cur
+		case strings.HasPrefix(str, "author "):
+			cur.author = str[len("author "):]
+		case strings.HasPrefix(str, "author-mail "):
+			cur.email = strings.Trim(str[len("author-mail "):], "<>")
+		case strings.HasPrefix(str, "author-time "):
+			sec, _ := strconv.ParseInt(str[len("author-time "):], 10, 64)
+			cur.time = time.Unix(sec, 0).UTC()
+		default:
+			fields := strings.Fields(str)
+			if len(fields) >= 3 && isCommitHash(fields[0]) {
+				cur = Blame{commit: fields[0]}
+				if isZeroCommit(cur.commit) {
+					cur.commit = Uncommitted
+				}
+				line, _ = strconv.Atoi(fields[2])
+			}
+		}
+	}
+	return result
+}
+
+// BlameTodos заполняет для списка блоков одного файла сведения о коммите,
+// который последним изменял строку начала блока. Строки отсутствующие в
+// репозитории отмечаются как не зафиксированные.
+func BlameTodos(project string, file string, todos []Todos) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	lines, err := BlameFile(project, file)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		b, ok := lines[todos[i].line]
+		if !ok || b.commit == Uncommitted {
+			b = Blame{commit: Uncommitted}
+		}
+		todos[i].blame = b
+	}
+	return nil
+}
+
+// shortCommit возвращает сокращённый хэш коммита
+func shortCommit(commit string) string {
+	if isCommitHash(commit) {
+		return commit[:7]
+	}
+	return commit
+}
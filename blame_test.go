@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test_Blame тестирует определение коммита для строк блоков. В временной
+// директории создаётся репозиторий с одним коммитом, после чего в файл
+// добавляется не зафиксированный блок. Первый блок должен получить автора и
+// коммит, второй быть отмечен как не зафиксированный.
+func Test_Blame(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Tester", "-c", "user.email=tester@example.com"},
+			args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out), err)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(file, []byte("// TODO: committed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "main.go")
+	git("commit", "-q", "-m", "init")
+	if err := os.WriteFile(file, []byte("// TODO: committed\n// FIXME: new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	todos := []Todos{NewTodos("TODO", " committed", file, 1),
+		NewTodos("FIXME", " new", file, 2)}
+	if err := BlameTodos(dir, file, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := todos[0].blame; b.author != "Tester" || b.email != "tester@example.com" ||
+		len(b.commit) != 40 || b.time.IsZero() {
+		t.Errorf("blame: первый блок: имеется: %v", b)
+	}
+	if b := todos[1].blame; b.commit != Uncommitted || b.author != "" {
+		t.Errorf("blame: второй блок: имеется: %v", b)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("// TODO: new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := BlameFile(dir, filepath.Join(dir, "new.go")); err != nil || len(lines) != 0 {
+		t.Errorf("blame: файл вне HEAD: %v %v", lines, err)
+	}
+	if _, err := BlameFile(dir, filepath.Join(filepath.Dir(dir), "outside.go")); err == nil {
+		t.Errorf("blame: нет ошибки для файла вне репозитория")
+	}
+}
+
+// Test_ParseBlame тестирует разбор вывода git blame --line-porcelain в
+// репозиториях с хэшами SHA-1 и SHA-256. Строки с нулевым хэшем должны
+// отмечаться как не зафиксированные.
+func Test_ParseBlame(t *testing.T) {
+	sha256 := strings.Repeat("ab", 32)
+	out := sha256 + " 1 1 1\nauthor Tester\nauthor-mail <tester@example.com>\n" +
+		"author-time 1700000000\n\tline one\n" +
+		strings.Repeat("0", 64) + " 2 2 1\nauthor Not Committed Yet\n\tline two\n"
+	lines := parseBlame([]byte(out))
+	if b := lines[1]; b.commit != sha256 || b.author != "Tester" || shortCommit(b.commit) != "abababa" {
+		t.Errorf("blame: SHA-256: имеется: %v", b)
+	}
+	if b := lines[2]; b.commit != Uncommitted {
+		t.Errorf("blame: нулевой хэш SHA-256: имеется: %v", b)
+	}
+}
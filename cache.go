@@ -0,0 +1,335 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheFormat версия формата файла кэша. Увеличивается при изменении формата
+// или алгоритма поиска комментариев.
+const cacheFormat = 2
+
+// CacheVersion возвращает версию кэша. Версия зависит от формата файла кэша и
+// реестра форматов файлов, поэтому изменение правил поиска комментариев
+// делает кэш недействительным.
+func CacheVersion() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d %#v", cacheFormat, Syntaxes)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cacheComment представление CommentLine в кэше
+type cacheComment struct {
+	Line int    `json:"l"`
+	Col  int    `json:"c"`
+	Data string `json:"d"`
+}
+
+// cacheBlame представление Blame в кэше
+type cacheBlame struct {
+	Commit string `json:"c"`
+	Author string `json:"a,omitempty"`
+	Email  string `json:"e,omitempty"`
+	Time   int64  `json:"t,omitempty"`
+}
+
+// cacheEntry сведения о файле и найденные в нём комментарии
+type cacheEntry struct {
+	Size      int64              `json:"size"`                 // размер файла
+	ModTime   int64              `json:"mtime"`                // время изменения в наносекундах
+	Hash      string             `json:"hash"`                 // sha256 содержимого файла
+	Syntax    string             `json:"syntax"`               // символы комментариев при разборе
+	Comments  []cacheComment     `json:"comments"`             // комментарии файла
+	BlameHead string             `json:"blame_head,omitempty"` // коммит HEAD при git blame
+	Blame     map[int]cacheBlame `json:"blame,omitempty"`      // коммиты строк блоков
+}
+
+// cacheFile содержимое файла кэша
+type cacheFile struct {
+	Version string                `json:"version"`
+	Files   map[string]cacheEntry `json:"files"`
+}
+
+// Cache хранит комментарии найденные в файлах между запусками программы.
+// Файл не разбирается повторно если не изменились его размер и время
+// изменения, либо содержимое. Записи файлов из корней поиска этого запуска,
+// которые при поиске не встретились, например, удалённых файлов, при
+// сохранении удаляются. Методы Cache безопасны для одновременного вызова из
+// нескольких горутин.
+type Cache struct {
+	path  string          // путь к файлу кэша
+	mu    sync.Mutex      // защищает dirty, data, seen и roots
+	dirty bool            // кэш изменён после загрузки
+	data  cacheFile       // содержимое кэша
+	seen  map[string]bool // ключи файлов, которые встретились при поиске
+	roots []string        // корни поиска этого запуска
+}
+
+// DefaultCachePath возвращает путь к файлу кэша в пользовательской
+// директории кэша.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todolist", "cache.json"), nil
+}
+
+// newCache возвращает пустой кэш. Кэш с пустым путём хранится только в
+// памяти.
+func newCache(path string) *Cache {
+	return &Cache{path: path, seen: map[string]bool{},
+		data: cacheFile{Version: CacheVersion(), Files: map[string]cacheEntry{}}}
+}
+
+// LoadCache загружает кэш из файла. Если файл не существует, повреждён или
+// имеет другую версию, возвращается пустой кэш.
+func LoadCache(path string) (*Cache, error) {
+	c := newCache(path)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	var data cacheFile
+	if err := json.Unmarshal(raw, &data); err != nil || data.Version != c.data.Version {
+		c.dirty = true
+		return c, nil
+	}
+	if data.Files != nil {
+		c.data.Files = data.Files
+	}
+	return c, nil
+}
+
+// Save записывает кэш в файл если он был изменён. Файл заменяется целиком,
+// поэтому прерванная запись не повреждает предыдущий кэш. Для nil кэша ничего
+// не делает.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(c.data)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "cache-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// FindComments возвращает комментарии файла из кэша или находит их функцией
+// FindComments и сохраняет в кэш. Ключом кэша служит key, обычно абсолютный
+// путь к файлу. Для nil кэша всегда вызывается FindComments.
+func (c *Cache) FindComments(fsd fs.FS, file string, key string, cs CommentSimbols) ([]CommentLine, error) {
+	if c == nil {
+		return FindComments(fsd, file, cs)
+	}
+	info, err := fs.Stat(fsd, file)
+	if err != nil {
+		return FindComments(fsd, file, cs)
+	}
+
+	syntax := fmt.Sprintf("%q", cs)
+	c.mu.Lock()
+	c.seen[key] = true
+	entry, ok := c.data.Files[key]
+	c.mu.Unlock()
+	// файл разобранный с другими символами комментариев разбирается заново
+	ok = ok && entry.Syntax == syntax
+	if ok && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() {
+		return entry.commentLines(), nil
+	}
+
+	src, err := fs.ReadFile(fsd, file)
+	if err != nil {
+		return FindComments(fsd, file, cs)
+	}
+	sum := sha256.Sum256(src)
+	hash := hex.EncodeToString(sum[:])
+
+	var comments []CommentLine
+	if ok && entry.Hash == hash {
+		comments = entry.commentLines()
+	} else {
+		comments, err = FindComments(fsd, file, cs)
+		if err != nil {
+			return comments, err
+		}
+	}
+
+	update := newCacheEntry(info, hash, syntax, comments)
+	if ok && entry.Hash == hash {
+		// содержимое не изменилось, сведения о коммитах остаются верными
+		update.BlameHead, update.Blame = entry.BlameHead, entry.Blame
+	}
+	c.mu.Lock()
+	c.data.Files[key] = update
+	c.dirty = true
+	c.mu.Unlock()
+	return comments, nil
+}
+
+// AddRoot добавляет корень поиска этого запуска, обычно абсолютный путь.
+// Записи файлов внутри корня, которые не встретились при поиске, удаляются
+// при сохранении кэша. Для nil кэша ничего не делает.
+func (c *Cache) AddRoot(root string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range c.roots {
+		if r == root {
+			return
+		}
+	}
+	c.roots = append(c.roots, root)
+}
+
+// prune удаляет записи файлов внутри корней поиска, которые не встретились
+// при поиске. Вызывается с захваченным mu.
+func (c *Cache) prune() {
+	for key := range c.data.Files {
+		if c.seen[key] {
+			continue
+		}
+		for _, root := range c.roots {
+			if inRoot(key, root) {
+				delete(c.data.Files, key)
+				c.dirty = true
+				break
+			}
+		}
+	}
+}
+
+// inRoot проверяет что путь p находится внутри корня root, в том числе
+// внутри архива root
+func inRoot(p string, root string) bool {
+	if !strings.HasPrefix(p, root) {
+		return false
+	}
+	rest := p[len(root):]
+	return rest == "" || strings.HasSuffix(root, string(filepath.Separator)) ||
+		rest[0] == filepath.Separator || strings.HasPrefix(rest, ArchiveSep)
+}
+
+// newCacheEntry возвращает запись кэша для файла
+func newCacheEntry(info fs.FileInfo, hash string, syntax string, comments []CommentLine) cacheEntry {
+	entry := cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(),
+		Hash: hash, Syntax: syntax, Comments: make([]cacheComment, 0, len(comments))}
+	for _, cl := range comments {
+		entry.Comments = append(entry.Comments, cacheComment{cl.line, cl.col, cl.data})
+	}
+	return entry
+}
+
+// commentLines возвращает комментарии записи кэша
+func (entry cacheEntry) commentLines() []CommentLine {
+	result := make([]CommentLine, 0, len(entry.Comments))
+	for _, cc := range entry.Comments {
+		result = append(result, CommentLine{cc.Line, cc.Col, cc.Data})
+	}
+	return result
+}
+
+// BlameTodos заполняет сведения о коммитах блоков файла функцией BlameTodos и
+// сохраняет их в записи файла в кэше вместе с коммитом head, обычно HEAD
+// репозитория. Запись файла сбрасывается при изменении содержимого, поэтому
+// если не изменились ни содержимое файла, ни head, git blame не вызывается.
+// Для nil кэша, пустого head или файла без записи в кэше всегда вызывается
+// BlameTodos.
+func (c *Cache) BlameTodos(project string, file string, key string, head string, todos []Todos) error {
+	if c == nil || head == "" || len(todos) == 0 {
+		return BlameTodos(project, file, todos)
+	}
+	c.mu.Lock()
+	entry, ok := c.data.Files[key]
+	c.mu.Unlock()
+	if !ok {
+		return BlameTodos(project, file, todos)
+	}
+	if entry.BlameHead == head {
+		hit := true
+		for i := range todos {
+			if _, found := entry.Blame[todos[i].line]; !found {
+				hit = false
+				break
+			}
+		}
+		if hit {
+			for i := range todos {
+				todos[i].blame = entry.Blame[todos[i].line].blame()
+			}
+			return nil
+		}
+	}
+
+	if err := BlameTodos(project, file, todos); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry = c.data.Files[key]
+	if entry.BlameHead != head || entry.Blame == nil {
+		entry.BlameHead, entry.Blame = head, map[int]cacheBlame{}
+	}
+	for i := range todos {
+		b := todos[i].blame
+		cb := cacheBlame{Commit: b.commit, Author: b.author, Email: b.email}
+		if !b.time.IsZero() {
+			cb.Time = b.time.Unix()
+		}
+		entry.Blame[todos[i].line] = cb
+	}
+	c.data.Files[key] = entry
+	c.dirty = true
+	return nil
+}
+
+// blame возвращает сведения о коммите записи кэша
+func (cb cacheBlame) blame() Blame {
+	b := Blame{commit: cb.Commit, author: cb.Author, email: cb.Email}
+	if cb.Time != 0 {
+		b.time = time.Unix(cb.Time, 0).UTC()
+	}
+	return b
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test_Cache тестирует кэш комментариев. Пока размер и время изменения файла
+// не изменились, комментарии должны возвращаться из кэша без разбора файла.
+// При изменении только времени изменения файл проверяется по хэшу
+// содержимого, при изменении содержимого разбирается заново. Кэш должен
+// сохраняться в файл и загружаться из него.
+func Test_Cache(t *testing.T) {
+	header := "кэш:"
+	cs, _ := FindSyntax("main.c")
+	mtime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsd := fstest.MapFS{"main.c": {Data: []byte("// first\n"), ModTime: mtime}}
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments := func() string {
+		t.Helper()
+		got, err := cache.FindComments(fsd, "main.c", "/src/main.c", cs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if guardLenght(t, header, 1, len(got)) {
+			t.Fatal(got)
+		}
+		return got[0].data
+	}
+
+	if got := comments(); got != " first" {
+		t.Errorf("%s разбор файла: имеется: %q", header, got)
+	}
+
+	// тот же размер и время изменения, файл не разбирается
+	fsd["main.c"].Data = []byte("// other\n")
+	if got := comments(); got != " first" {
+		t.Errorf("%s файл без изменений: имеется: %q", header, got)
+	}
+
+	// изменилось время изменения, содержимое сверяется по хэшу
+	fsd["main.c"].ModTime = mtime.Add(time.Hour)
+	if got := comments(); got != " other" {
+		t.Errorf("%s изменённый файл: имеется: %q", header, got)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsd["main.c"].Data = []byte("// third\n")
+	if got := comments(); got != " other" {
+		t.Errorf("%s загруженный кэш: имеется: %q", header, got)
+	}
+}
+
+// Test_CachePrune тестирует удаление из кэша записей файлов, которые не
+// встретились при поиске в корне поиска, например, удалённых файлов. Записи
+// файлов вне корней поиска этого запуска должны сохраняться.
+func Test_CachePrune(t *testing.T) {
+	header := "очистка кэша:"
+	cs, _ := FindSyntax("main.c")
+	fsd := fstest.MapFS{"main.c": {Data: []byte("// first\n")}}
+	path := filepath.Join(t.TempDir(), "cache.json")
+	keys := []string{filepath.FromSlash("/src/a.c"), filepath.FromSlash("/src/b.c"),
+		filepath.FromSlash("/src.tar!b.c"), filepath.FromSlash("/srcdir/c.c")}
+
+	cache, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := cache.FindComments(fsd, "main.c", key, cs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache, err = LoadCache(path); err != nil {
+		t.Fatal(err)
+	}
+	cache.AddRoot(filepath.FromSlash("/src"))
+	cache.AddRoot(filepath.FromSlash("/src.tar"))
+	if _, err := cache.FindComments(fsd, "main.c", keys[0], cs); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if cache, err = LoadCache(path); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for key := range cache.data.Files {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	want := []string{keys[0], keys[3]}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
+
+// Test_CacheBlame тестирует кэш сведений о коммитах. Повторный поиск без
+// изменений файла и HEAD не должен вызывать git blame, что проверяется
+// поиском без git в PATH, а новый коммит должен обновлять сведения. С флагом
+// -blame=false сведения о коммитах не выводятся.
+func Test_CacheBlame(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	header := "кэш blame:"
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Tester", "-c", "user.email=tester@example.com"},
+			args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out), err)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(file, []byte("// TODO: committed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "main.go")
+	git("commit", "-q", "-m", "init")
+	if err := os.WriteFile(file, []byte("// TODO: committed\n// FIXME: new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt := DefaultScanOptions()
+	opt.markers, opt.cache = []string{".git"}, newCache("")
+	scan := func() []string {
+		t.Helper()
+		todos, errs := Scan(os.DirFS(dir), dir, ".", opt)
+		if len(errs) != 0 {
+			t.Fatal(errs)
+		}
+		got := make([]string, 0)
+		for i := range todos {
+			got = append(got, todos[i].tag+" "+shortCommit(todos[i].blame.commit))
+		}
+		return got
+	}
+	first := scan()
+	if len(first) != 2 || first[1] != "FIXME "+Uncommitted {
+		t.Fatalf("%s первый поиск: %v", header, first)
+	}
+
+	path := os.Getenv("PATH")
+	t.Setenv("PATH", "")
+	compareStrings(t, header, first, scan())
+	os.Setenv("PATH", path)
+
+	git("add", "main.go")
+	git("commit", "-q", "-m", "fixme")
+	if got := scan(); len(got) != 2 || got[1] == "FIXME "+Uncommitted {
+		t.Errorf("%s после коммита: %v", header, got)
+	}
+
+	var stdout, stderr strings.Builder
+	if code := run([]string{"-no-cache", "-blame=false", "-format", "json", dir},
+		&stdout, &stderr); code != exitOK || strings.Contains(stdout.String(), `"commit"`) {
+		t.Errorf("%s -blame=false: код %d, вывод %s", header, code, stdout.String())
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Коды завершения программы
+const (
+	exitOK    = 0 // поиск выполнен
+	exitError = 1 // ошибка вывода, недоступная директория или -strict
+	exitUsage = 2 // неверные аргументы командной строки
+)
+
+// listFlag флаг командной строки, который можно указать несколько раз.
+// Значения могут быть перечислены через запятую.
+type listFlag []string
+
+func (lf *listFlag) String() string { return strings.Join(*lf, ",") }
+
+func (lf *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*lf = append(*lf, v)
+		}
+	}
+	return nil
+}
+
+// extPattern возвращает файловый шаблон для расширения файла. Расширение
+// можно указать как «go», «.go» или шаблоном «*.go».
+func extPattern(ext string) string {
+	if strings.ContainsAny(ext, "*?[") {
+		return ext
+	}
+	return "*." + strings.TrimPrefix(ext, ".")
+}
+
+// usage выводит справку по использованию программы
+func usage(fset *flag.FlagSet) func() {
+	return func() {
+		w := fset.Output()
+		fmt.Fprintln(w, `Использование: todolist [флаги] [директория или архив ...]
+       todolist команда [флаги] [аргументы ...]
+
+Находит проекты в указанных директориях или архивах zip и tar, по умолчанию в
+текущей директории, и выводит блоки комментариев отмеченные тегами TODO, FIXME
+и другими.
+
+Команды:
+  due       просроченные блоки и блоки со сроком в пределах окна
+  baseline  сохранение базового списка блоков и проверка новых блоков
+  diff      блоки добавленные, удалённые и изменённые между ревизиями git
+  patch     блоки добавленные, удалённые и изменённые файлом unified diff
+  serve     сервер HTTP со списком блоков и JSON API
+
+Флаги:`)
+		fset.PrintDefaults()
+		fmt.Fprintln(w, `
+Коды завершения: 0 поиск выполнен, 1 ошибка вывода, недоступная директория
+или ошибки поиска с флагом -strict, 2 неверные аргументы.`)
+	}
+}
+
+// Commands команды программы по их названию. Команда получает аргументы после
+// своего названия и возвращает код завершения программы.
+var Commands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"due":      runDue,
+	"baseline": runBaseline,
+	"diff":     runDiff,
+	"patch": func(args []string, stdout, stderr io.Writer) int {
+		return runPatch(args, os.Stdin, stdout, stderr)
+	},
+	"serve": runServe,
+}
+
+// scanFlags флаги поиска общие для всех команд
+type scanFlags struct {
+	fset     *flag.FlagSet
+	opt      ScanOptions
+	markers  listFlag
+	exts     listFlag
+	tags     listFlag
+	excludes listFlag
+	nested   *bool
+	noCache  *bool
+	rev      string   // ревизия git для поиска, пустая для рабочей копии
+	fss      []fs.FS  // файловые системы корней поиска
+	bases    []string // начало путей блоков корней поиска
+}
+
+// newScanFlags добавляет флаги поиска к набору флагов команды
+func newScanFlags(fset *flag.FlagSet) *scanFlags {
+	sf := &scanFlags{fset: fset, opt: DefaultScanOptions()}
+	fset.Var(&sf.markers, "marker", "маркер проекта, файловый шаблон (можно повторять)")
+	fset.Var(&sf.exts, "ext", "расширение или шаблон файлов для поиска (можно повторять)")
+	fset.Var(&sf.tags, "tag", "тег блока комментариев (можно повторять)")
+	fset.Var(&sf.excludes, "exclude", "исключение в синтаксисе .gitignore (можно повторять)")
+	sf.nested = fset.Bool("nested", false, "искать проекты вложенные в другие проекты")
+	fset.IntVar(&sf.opt.jobs, "jobs", sf.opt.jobs, "количество одновременно работающих горутин")
+	sf.noCache = fset.Bool("no-cache", false, "не использовать кэш комментариев")
+	fset.BoolVar(&sf.opt.blame, "blame", sf.opt.blame,
+		"получать сведения о коммитах git для блоков, -blame=false отключает git blame")
+	return sf
+}
+
+// revFlag добавляет флаг -rev, ревизию git для поиска без рабочей копии
+func (sf *scanFlags) revFlag() {
+	sf.fset.StringVar(&sf.rev, "rev", "",
+		"искать в ревизии git, например, v1.2.0, без переключения рабочей копии")
+}
+
+// isSet возвращает true если флаг указан в командной строке
+func isSet(fset *flag.FlagSet, name string) bool {
+	set := false
+	fset.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// setup проверяет разобранные флаги и директории поиска, загружает настройки
+// корней поиска и кэш. Директории поиска по умолчанию текущая директория.
+// Вместо директории можно указать архив zip или tar, пути блоков внутри
+// архива указываются после пути к архиву и разделителя ArchiveSep. Если
+// указана ревизия -rev, корни поиска читаются из ревизии их репозитория git,
+// а кэш и сведения о коммитах не используются. Если код завершения не равен
+// exitOK, команда должна завершиться с ним.
+func (sf *scanFlags) setup(roots []string, stderr io.Writer) ([]string, []*Config, int) {
+	if sf.opt.jobs < 1 {
+		fmt.Fprintln(stderr, "количество горутин должно быть больше нуля:", sf.opt.jobs)
+		return nil, nil, exitUsage
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err == nil && !info.IsDir() && isArchive(root) && sf.rev == "" {
+			continue
+		}
+		if err != nil || !info.IsDir() {
+			fmt.Fprintln(stderr, "директория поиска недоступна:", root)
+			return nil, nil, exitError
+		}
+	}
+
+	// флаги командной строки важнее файлов настроек
+	cli := &Config{}
+	if len(sf.markers) > 0 {
+		cli.Markers = sf.markers
+	}
+	for _, ext := range sf.exts {
+		cli.Include = append(cli.Include, extPattern(ext))
+	}
+	if len(sf.tags) > 0 {
+		cli.Tags = sf.tags
+	}
+	if len(sf.excludes) > 0 {
+		cli.Exclude = sf.excludes
+	}
+	if isSet(sf.fset, "nested") {
+		cli.Nested = sf.nested
+	}
+	sf.opt.cli = cli
+
+	cfgs := make([]*Config, len(roots))
+	sf.fss = make([]fs.FS, len(roots))
+	sf.bases = make([]string, len(roots))
+	for i, root := range roots {
+		sf.fss[i], sf.bases[i] = os.DirFS(root), root
+		if isArchive(root) && sf.rev == "" {
+			afs, err := OpenArchive(root)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				sf.close(stderr)
+				return nil, nil, exitError
+			}
+			sf.fss[i], sf.bases[i] = afs, root+ArchiveSep
+			// архив рабочей копии содержит .git, но git не может читать архив
+			sf.opt.blame = false
+		} else if sf.rev != "" {
+			rfs, err := GitRevFS(root, sf.rev)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				sf.close(stderr)
+				return nil, nil, exitError
+			}
+			sf.fss[i] = rfs
+		}
+		cfg, err := LoadConfig(sf.fss[i], ".")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			sf.close(stderr)
+			return nil, nil, exitUsage
+		}
+		cfgs[i] = cfg
+	}
+
+	if sf.rev != "" {
+		// у файлов ревизии нет времени изменения, а строки нет в рабочей копии
+		sf.opt.blame = false
+	} else if !*sf.noCache {
+		if path, err := DefaultCachePath(); err == nil {
+			cache, err := LoadCache(path)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+			sf.opt.cache = cache
+		}
+	}
+	return roots, cfgs, exitOK
+}
+
+// options возвращает параметры поиска корня с настройками cfg
+func (sf *scanFlags) options(cfg *Config) ScanOptions {
+	return sf.opt.Apply(cfg).Apply(sf.opt.cli)
+}
+
+// scan выполняет поиск во всех корнях и выводит ошибки поиска в stderr
+func (sf *scanFlags) scan(roots []string, cfgs []*Config, stderr io.Writer) ([]Todos, []error) {
+	result := make([]Todos, 0)
+	scanerrs := make([]error, 0)
+	for i := range roots {
+		todos, errs := Scan(sf.fss[i], sf.bases[i], ".", sf.options(cfgs[i]))
+		for _, err := range errs {
+			fmt.Fprintln(stderr, err)
+		}
+		result = append(result, todos...)
+		scanerrs = append(scanerrs, errs...)
+	}
+	return result, scanerrs
+}
+
+// close сохраняет кэш комментариев и закрывает архивы и файловые системы
+// ревизий git
+func (sf *scanFlags) close(stderr io.Writer) {
+	if err := sf.opt.cache.Save(); err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+	for _, fsd := range sf.fss {
+		if c, ok := fsd.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// run разбирает аргументы командной строки, выполняет поиск и выводит
+// результат. Если первый аргумент название команды из Commands, выполняет
+// команду. Возвращает код завершения программы.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 {
+		if cmd, ok := Commands[args[0]]; ok {
+			return cmd(args[1:], stdout, stderr)
+		}
+	}
+
+	fset := flag.NewFlagSet("todolist", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = usage(fset)
+
+	sf := newScanFlags(fset)
+	sf.revFlag()
+	format := fset.String("format", "org", "формат вывода: org, json, jsonl, sarif или quickfix")
+	output := fset.String("output", "", "файл для вывода, по умолчанию стандартный вывод")
+	strict := fset.Bool("strict", false, "завершаться с кодом 1 при ошибках поиска")
+	wopt := DefaultWatchOptions()
+	watch := fset.Bool("watch", false, "повторять поиск при изменении файлов")
+	fset.BoolVar(&wopt.events, "events", false,
+		"в режиме -watch выводить появление и исчезновение блоков")
+	fset.BoolVar(&wopt.poll, "poll", false, "в режиме -watch опрашивать файлы вместо inotify")
+	if err := fset.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *watch && fset.NArg() > 1 {
+		fmt.Fprintln(stderr, "в режиме -watch можно указать только одну директорию")
+		return exitUsage
+	}
+	if *watch && (sf.rev != "" || isArchive(fset.Arg(0))) {
+		fmt.Fprintln(stderr, "режим -watch нельзя использовать с -rev и архивами")
+		return exitUsage
+	}
+
+	roots, cfgs, code := sf.setup(fset.Args(), stderr)
+	if code != exitOK {
+		return code
+	}
+	defer sf.close(stderr)
+
+	// формат вывода из настроек первой директории
+	if cfgs[0] != nil && cfgs[0].Format != "" && !isSet(fset, "format") {
+		*format = cfgs[0].Format
+	}
+	write, ok := Writers[*format]
+	if !ok {
+		fmt.Fprintln(stderr, "неизвестный формат вывода:", *format)
+		return exitUsage
+	}
+
+	out := stdout
+	if *output != "" && !(*watch && !wopt.events) {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if *watch {
+		wopt.write = write
+		if *output != "" && !wopt.events {
+			// отчёт в файле заменяется после каждого поиска
+			wopt.write = func(w io.Writer, todos []Todos) error {
+				return writeFile(*output, todos, write)
+			}
+		}
+		stop := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			close(stop)
+		}()
+		root := roots[0]
+		if rel, ok := outputIn(root, *output); ok {
+			// запись отчёта не должна вызывать повторный поиск
+			wopt.exclude = []string{rel}
+		}
+		err := Watch(os.DirFS(root), root, ".", sf.options(cfgs[0]), wopt, out, stderr, stop)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	result, scanerrs := sf.scan(roots, cfgs, stderr)
+	var err error
+	if ew, ok := ErrorWriters[*format]; ok {
+		err = ew(out, result, scanerrs)
+	} else {
+		err = write(out, result)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	WriteErrorSummary(stderr, scanerrs)
+	if *strict && len(scanerrs) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// outputIn возвращает путь файла отчёта output относительно директории root
+// в формате fs.FS. Если отчёт не указан или находится вне root, второе
+// значение равно false.
+func outputIn(root string, output string) (string, bool) {
+	if output == "" {
+		return "", false
+	}
+	absRoot, err1 := filepath.Abs(root)
+	absOutput, err2 := filepath.Abs(output)
+	if err1 != nil || err2 != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, absOutput)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// writeFile записывает отчёт в файл, заменяя его содержимое
+func writeFile(path string, todos []Todos, write func(io.Writer, []Todos) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, todos); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test_Run тестирует разбор аргументов командной строки. Поиск должен
+// выполняться по нескольким относительным путям, флаги -tag, -ext и -marker
+// заменять параметры по умолчанию, а неверные аргументы завершаться кодом 2.
+func Test_Run(t *testing.T) {
+	header := "командная строка:"
+	var stdout, stderr strings.Builder
+	code := run([]string{"-no-cache", "-format", "quickfix", "-tag", "TODO",
+		"-marker", "go.mod", "-ext", "go", "testdata/hello", "testdata/world"},
+		&stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("%s код завершения: %d, %s", header, code, stderr.String())
+	}
+	got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	want := []string{"testdata/hello/main_hello.go:2:1: TODO: in hello Line two",
+		"testdata/world/main_world.go:1:1: TODO: in world"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	output := filepath.Join(t.TempDir(), "todo.json")
+	stdout.Reset()
+	code = run([]string{"-no-cache", "-format", "json", "-output", output, "testdata"},
+		&stdout, &stderr)
+	if code != exitOK || stdout.Len() != 0 {
+		t.Errorf("%s вывод в файл: код %d, вывод %q", header, code, stdout.String())
+	}
+	if data, err := os.ReadFile(output); err != nil || !strings.Contains(string(data), "in world") {
+		t.Errorf("%s вывод в файл: %q %v", header, data, err)
+	}
+
+	for _, args := range [][]string{{"-format", "xml", "testdata"}, {"-jobs", "0"},
+		{"-unknown"}, {"-watch", "testdata/hello", "testdata/world"}} {
+		if code := run(append([]string{"-no-cache"}, args...), &stdout, &stderr); code != exitUsage {
+			t.Errorf("%s %v: требуется код %d, имеется: %d", header, args, exitUsage, code)
+		}
+	}
+	if code := run([]string{"-no-cache", "testdata/missing"}, &stdout, &stderr); code != exitError {
+		t.Errorf("%s нет директории: требуется код %d, имеется: %d", header, exitError, code)
+	}
+}
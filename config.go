@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// ConfigFiles имена файлов настроек в порядке поиска. В директории
+// используется первый найденный файл.
+var ConfigFiles = []string{".todolist.toml", ".todolist.json"}
+
+// SyntaxConfig символы комментариев формата файла в файле настроек
+type SyntaxConfig struct {
+	Line   string `json:"line"`   // символ одно строчного комментария
+	Open   string `json:"open"`   // символ начала много строчного комментария
+	Close  string `json:"close"`  // символ конца много строчного комментария
+	Quotes string `json:"quotes"` // символы строк с экранированием
+	Raw    string `json:"raw"`    // символы строк без экранирования
+	Multi  string `json:"multi"`  // символы много строчных строк через пробел
+}
+
+// Config настройки поиска из файла .todolist.toml или .todolist.json. Не
+// указанные в файле параметры не изменяют параметры предыдущего уровня.
+//
+// Настройки из корня поиска применяются ко всем проектам и дополнительно
+// задают маркеры проекта и формат вывода, настройки проекта применяются
+// только к файлам проекта.
+type Config struct {
+	Markers []string                `json:"markers"` // маркеры проекта
+	Include []string                `json:"include"` // шаблоны файлов для поиска
+	Exclude []string                `json:"exclude"` // исключения в синтаксисе .gitignore
+	Tags    []string                `json:"tags"`    // теги блоков
+	Format  string                  `json:"format"`  // формат вывода
+	Nested  *bool                   `json:"nested"`  // искать вложенные проекты
+	Syntax  map[string]SyntaxConfig `json:"syntax"`  // символы комментариев по шаблону файла
+}
+
+// LoadConfig загружает настройки из директории dir. Если файла настроек в
+// директории нет, возвращает nil. Неизвестные параметры считаются ошибкой.
+func LoadConfig(fsd fs.FS, dir string) (*Config, error) {
+	for _, name := range ConfigFiles {
+		file := path.Join(dir, name)
+		data, err := fs.ReadFile(fsd, file)
+		if err != nil {
+			continue
+		}
+		if path.Ext(name) == ".toml" {
+			tree, err := parseTOML(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if data, err = json.Marshal(tree); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		var cfg Config
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return &cfg, nil
+	}
+	return nil, nil
+}
+
+// Apply возвращает параметры поиска с применёнными настройками. Символы
+// комментариев из настроек проверяются раньше встроенного реестра форматов.
+func (opt ScanOptions) Apply(cfg *Config) ScanOptions {
+	if cfg == nil {
+		return opt
+	}
+	if cfg.Markers != nil {
+		opt.markers = cfg.Markers
+	}
+	if cfg.Include != nil {
+		opt.include = cfg.Include
+	}
+	if cfg.Exclude != nil {
+		opt.exclude = cfg.Exclude
+	}
+	if cfg.Tags != nil {
+		opt.tags = cfg.Tags
+	}
+	if cfg.Nested != nil {
+		opt.nested = *cfg.Nested
+	}
+	if len(cfg.Syntax) > 0 {
+		patterns := make([]string, 0, len(cfg.Syntax))
+		for pattern := range cfg.Syntax {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		syntaxes := make([]Syntax, 0, len(patterns)+len(opt.syntaxes))
+		for _, pattern := range patterns {
+			sc := cfg.Syntax[pattern]
+			syntaxes = append(syntaxes, Syntax{pattern, []string{pattern},
+				CommentSimbols{sc.Line, sc.Open, sc.Close, sc.Quotes, sc.Raw, sc.Multi}})
+		}
+		opt.syntaxes = append(syntaxes, opt.syntaxes...)
+	}
+	return opt
+}
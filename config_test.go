@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// Test_TOML тестирует разбор подмножества TOML: пары ключ значение,
+// комментарии, массивы на нескольких строках и таблицы с ключами в кавычках.
+func Test_TOML(t *testing.T) {
+	data := `# настройки
+markers = [".git", 'go.mod'] # маркеры
+jobs = 4
+strict = true
+tags = [
+  "TODO",
+  "FIXME", # последний
+]
+
+[syntax."*.proto"]
+line = "//"
+quotes = "\"'"
+`
+	got, err := parseTOML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"markers": []interface{}{".git", "go.mod"},
+		"jobs":    int64(4),
+		"strict":  true,
+		"tags":    []interface{}{"TODO", "FIXME"},
+		"syntax": map[string]interface{}{
+			"*.proto": map[string]interface{}{"line": "//", "quotes": `"'`}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("toml: требуется: %v, имеется: %v", want, got)
+	}
+
+	for _, bad := range []string{"a = ", "a = \"open", "[a", "a = 1\na = 2", "a = [1 2]"} {
+		if _, err := parseTOML(bad); err == nil {
+			t.Errorf("toml: нет ошибки для %q", bad)
+		}
+	}
+}
+
+// Test_Config тестирует применение настроек. Настройки корня поиска
+// применяются ко всем проектам, настройки проекта только к его файлам и
+// поверх настроек корня. Символы комментариев из настроек дополняют реестр
+// форматов.
+func Test_Config(t *testing.T) {
+	header := "настройки:"
+	fsd := fstest.MapFS{
+		"src/.todolist.toml": {Data: []byte(`
+markers = ["go.mod"]
+tags = ["TODO", "FIXME"]
+format = "json"
+
+[syntax."*.proto"]
+line = "//"
+`)},
+		"src/hello/go.mod":          {},
+		"src/hello/main.go":         {Data: []byte("// FIXME: hello\n// HACK: skip\n")},
+		"src/hello/api.proto":       {Data: []byte("// TODO: proto\n")},
+		"src/world/go.mod":          {},
+		"src/world/.todolist.json":  {Data: []byte(`{"tags": ["HACK"], "exclude": ["gen/"]}`)},
+		"src/world/main.go":         {Data: []byte("// FIXME: skip\n// HACK: world\n")},
+		"src/world/gen/main.go":     {Data: []byte("// HACK: generated\n")},
+		"src/broken/go.mod":         {},
+		"src/broken/.todolist.json": {Data: []byte(`{"tag": ["HACK"]}`)},
+	}
+
+	cfg, err := LoadConfig(fsd, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil || cfg.Format != "json" {
+		t.Fatalf("%s корень: имеется: %v", header, cfg)
+	}
+
+	todos, errs := Scan(fsd, "", "src", DefaultScanOptions().Apply(cfg))
+	if len(errs) != 1 {
+		t.Errorf("%s ожидается ошибка неизвестного параметра: %v", header, errs)
+	}
+	got := make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].position+" "+todos[i].tag)
+	}
+	want := []string{"src/hello/api.proto:1 TODO", "src/hello/main.go:1 FIXME",
+		"src/world/main.go:2 HACK"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
+
+// Test_ConfigGoSyntax тестирует переопределение символов комментариев Go
+// файлов в настройках. Такие файлы разбираются с символами из настроек, а не
+// go/scanner.
+func Test_ConfigGoSyntax(t *testing.T) {
+	header := "символы комментариев go:"
+	fsd := fstest.MapFS{
+		"src/.todolist.toml": {Data: []byte(`
+markers = ["go.mod"]
+
+[syntax."*.go"]
+line = "#"
+`)},
+		"src/hello/go.mod":  {},
+		"src/hello/main.go": {Data: []byte("# TODO: hash\n// TODO: slash\n")},
+	}
+
+	cfg, err := LoadConfig(fsd, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos, errs := Scan(fsd, "", "src", DefaultScanOptions().Apply(cfg))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	got := make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].position+todos[i].lines[0])
+	}
+	want := []string{"src/hello/main.go:1 hash"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
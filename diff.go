@@ -0,0 +1,246 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MainBranches ветки, с которыми по умолчанию сравнивается рабочая копия в
+// команде diff, в порядке проверки
+var MainBranches = []string{"main", "master", "origin/main", "origin/master"}
+
+// TodoChange изменение блока между двумя ревизиями
+type TodoChange struct {
+	Kind   byte  // '+' блок добавлен, '-' удалён, '~' изменён
+	Before Todos // блок в старой ревизии, если блок не добавлен
+	After  Todos // блок в новой ревизии, если блок не удалён
+}
+
+// headKey возвращает ключ блока для поиска изменённых блоков: проект, файл,
+// тег и первая строка текста блока
+func headKey(td Todos) string {
+	head := ""
+	if len(td.lines) > 0 {
+		head = normalizeText(td.lines[:1])
+	}
+	return filepath.ToSlash(td.project) + "\x00" + filepath.ToSlash(td.file) +
+		"\x00" + td.tag + "\x00" + head
+}
+
+// DiffTodos сравнивает блоки двух ревизий. Блоки с одинаковыми отпечатком
+// Fingerprint и сведениями после тега считаются неизменными, их номера строк
+// не учитываются. Из оставшихся блоков блоки с одинаковыми проектом, файлом,
+// тегом и первой строкой текста считаются изменёнными, остальные
+// добавленными или удалёнными. Изменения возвращаются в порядке блоков новой
+// ревизии, удалённые блоки после них в порядке старой ревизии.
+func DiffTodos(before []Todos, after []Todos) []TodoChange {
+	key := func(td Todos) string { return Fingerprint(td) + "\x00" + td.meta.String() }
+	same := map[string]int{}
+	for _, td := range before {
+		same[key(td)]++
+	}
+	unmatched := make([]Todos, 0)
+	for _, td := range after {
+		if same[key(td)] > 0 {
+			same[key(td)]--
+			continue
+		}
+		unmatched = append(unmatched, td)
+	}
+
+	removed := map[string][]Todos{}
+	order := make([]Todos, 0)
+	for _, td := range before {
+		if same[key(td)] > 0 {
+			same[key(td)]--
+			removed[headKey(td)] = append(removed[headKey(td)], td)
+			order = append(order, td)
+		}
+	}
+
+	changes := make([]TodoChange, 0)
+	paired := map[string]int{}
+	for _, td := range unmatched {
+		hk := headKey(td)
+		if list := removed[hk]; paired[hk] < len(list) {
+			changes = append(changes, TodoChange{'~', list[paired[hk]], td})
+			paired[hk]++
+			continue
+		}
+		changes = append(changes, TodoChange{Kind: '+', After: td})
+	}
+	for _, td := range order {
+		hk := headKey(td)
+		if paired[hk] > 0 {
+			// первые блоки списка уже сопоставлены изменённым
+			paired[hk]--
+			continue
+		}
+		changes = append(changes, TodoChange{Kind: '-', Before: td})
+	}
+	return changes
+}
+
+// WriteChanges выводит изменения строками сообщений компилятора с символом
+// изменения в начале: «+» для добавленных блоков, «-» для удалённых и «~» для
+// изменённых. Положение удалённого блока указывается в старой ревизии,
+// остальных в новой.
+func WriteChanges(w io.Writer, changes []TodoChange) error {
+	wd, _ := os.Getwd()
+	bw := bufio.NewWriter(w)
+	for _, ch := range changes {
+		td := ch.After
+		if ch.Kind == '-' {
+			td = ch.Before
+		}
+		bw.WriteString(string(ch.Kind) + " " + td.quickfix(wd) + "\n")
+	}
+	return bw.Flush()
+}
+
+// WriteChangesJSON выводит изменения как один JSON документ со списками
+// added, removed и modified.
+func WriteChangesJSON(w io.Writer, changes []TodoChange) error {
+	type modified struct {
+		Before Todos `json:"before"` // блок в старой ревизии
+		After  Todos `json:"after"`  // блок в новой ревизии
+	}
+	report := struct {
+		Version  int        `json:"version"`  // версия схемы
+		Added    []Todos    `json:"added"`    // добавленные блоки
+		Removed  []Todos    `json:"removed"`  // удалённые блоки
+		Modified []modified `json:"modified"` // изменённые блоки
+	}{SchemaVersion, []Todos{}, []Todos{}, []modified{}}
+	for _, ch := range changes {
+		switch ch.Kind {
+		case '+':
+			report.Added = append(report.Added, ch.After)
+		case '-':
+			report.Removed = append(report.Removed, ch.Before)
+		case '~':
+			report.Modified = append(report.Modified, modified{ch.Before, ch.After})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// mergeBase возвращает общего предка HEAD и первой найденной ветки из
+// MainBranches
+func mergeBase(dir string) (string, error) {
+	for _, branch := range MainBranches {
+		if _, err := gitOutput(dir, "rev-parse", "--verify", "--quiet", branch+"^{commit}"); err != nil {
+			continue
+		}
+		out, err := gitOutput(dir, "merge-base", "HEAD", branch)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", fmt.Errorf("не найдена основная ветка: %s", strings.Join(MainBranches, ", "))
+}
+
+// runDiff выполняет команду diff: сравнивает блоки двух ревизий репозитория
+// git и выводит добавленные, удалённые и изменённые блоки.
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	fset := flag.NewFlagSet("todolist diff", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), `Использование: todolist diff [флаги] [ревизия-a [ревизия-b]]
+
+Сравнивает блоки двух ревизий репозитория git и выводит добавленные «+»,
+удалённые «-» и изменённые «~» блоки. По умолчанию ревизия-a общий предок
+HEAD и основной ветки, ревизия-b рабочая копия.
+
+Флаги:`)
+		fset.PrintDefaults()
+	}
+	sf := newScanFlags(fset)
+	repo := fset.String("repo", ".", "директория репозитория git")
+	format := fset.String("format", "text", "формат вывода: text или json")
+	if err := fset.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fset.NArg() > 2 {
+		fset.Usage()
+		return exitUsage
+	}
+	write := WriteChanges
+	switch *format {
+	case "text":
+	case "json":
+		write = WriteChangesJSON
+	default:
+		fmt.Fprintln(stderr, "неизвестный формат вывода:", *format)
+		return exitUsage
+	}
+
+	_, cfgs, code := sf.setup([]string{*repo}, stderr)
+	if code != exitOK {
+		return code
+	}
+	defer sf.close(stderr)
+	sf.opt.blame = false
+
+	revs := fset.Args()
+	if len(revs) == 0 {
+		base, err := mergeBase(*repo)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		revs = []string{base}
+	}
+
+	todos := make([][]Todos, 2)
+	scanerrs := make([]error, 0)
+	for i := range todos {
+		var fsd fs.FS = os.DirFS(*repo)
+		opt := sf.options(cfgs[0])
+		if i < len(revs) {
+			rfs, err := GitRevFS(*repo, revs[i])
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+			defer rfs.Close()
+			cfg, err := LoadConfig(rfs, ".")
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+			// у файлов ревизии нет времени изменения, кэш не используется
+			fsd, opt = rfs, sf.opt
+			opt.cache = nil
+			opt = opt.Apply(cfg).Apply(opt.cli)
+		}
+		var errs []error
+		todos[i], errs = Scan(fsd, *repo, ".", opt)
+		for _, err := range errs {
+			fmt.Fprintln(stderr, err)
+		}
+		scanerrs = append(scanerrs, errs...)
+	}
+
+	if err := write(stdout, DiffTodos(todos[0], todos[1])); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	WriteErrorSummary(stderr, scanerrs)
+	return exitOK
+}
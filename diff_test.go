@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test_Diff тестирует сравнение блоков ревизии и рабочей копии. В временной
+// директории создаётся репозиторий с одним коммитом и веткой, после чего
+// файл изменяется: блок перемещается, продолжение блока меняется, один блок
+// удаляется и один добавляется. Перемещённый блок не должен попасть в вывод.
+func Test_Diff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	header := "diff:"
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Tester", "-c", "user.email=tester@example.com"},
+			args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out), err)
+		}
+	}
+	git("init", "-q")
+	src := "package x\n// TODO: keep\n\n// FIXME: fix bug\n// in parser\n\n// TODO: gone\n"
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "main.go")
+	git("commit", "-q", "-m", "init")
+	git("checkout", "-q", "-b", "feature")
+	src = "package x\n\nfunc f() {}\n\n// TODO: keep\n\n// FIXME: fix bug\n// in lexer\n\n// TODO(bob): new\n"
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rfs, err := GitRevFS(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rfs.Close()
+	if err := fstest.TestFS(rfs, "main.go"); err != nil {
+		t.Errorf("%s файловая система ревизии: %v", header, err)
+	}
+
+	var stdout, stderr strings.Builder
+	if code := run([]string{"diff", "-no-cache", "-repo", dir}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("%s код завершения: %d, %s", header, code, stderr.String())
+	}
+	got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	want := []string{"~ main.go:7:1: FIXME: fix bug in lexer",
+		"+ main.go:10:1: TODO(bob): new", "- main.go:7:1: TODO: gone"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i][:1]) || !strings.HasSuffix(got[i], want[i][2:]) {
+			t.Errorf("%s %d: требуется: %s, имеется: %s", header, i, want[i], got[i])
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"diff", "-no-cache", "-repo", dir, "HEAD", "HEAD"},
+		&stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+		t.Errorf("%s одна ревизия: код %d, вывод %q", header, code, stdout.String())
+	}
+	if code := run([]string{"diff", "-no-cache", "-repo", dir, "missing"},
+		&stdout, &stderr); code != exitError {
+		t.Errorf("%s нет ревизии: требуется код %d, имеется: %d", header, exitError, code)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseWithin разбирает длительность окна срока. Кроме записи
+// time.ParseDuration принимает дни и недели, например, 14d или 2w.
+func parseWithin(str string) (time.Duration, error) {
+	day := 24 * time.Hour
+	for suffix, unit := range map[string]time.Duration{"d": day, "w": 7 * day} {
+		if num := strings.TrimSuffix(str, suffix); num != str {
+			n, err := strconv.Atoi(num)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("неверная длительность %q", str)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("неверная длительность %q", str)
+	}
+	return d, nil
+}
+
+// today возвращает начало дня t как дату в UTC, так же разбираются сроки
+// блоков.
+func today(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DueTodos возвращает блоки со сроком раньше дня now или не позже чем через
+// within от дня now, отсортированные по сроку, и количество просроченных
+// блоков. Блок со сроком в день now не считается просроченным.
+func DueTodos(todos []Todos, now time.Time, within time.Duration) ([]Todos, int) {
+	day := today(now)
+	limit := day.Add(within)
+	result := make([]Todos, 0)
+	overdue := 0
+	for _, td := range todos {
+		if td.meta.due.IsZero() || td.meta.due.After(limit) {
+			continue
+		}
+		if td.meta.due.Before(day) {
+			overdue++
+		}
+		result = append(result, td)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].meta.due.Before(result[j].meta.due)
+	})
+	return result, overdue
+}
+
+// runDue выполняет команду due: выводит просроченные блоки и блоки срок
+// которых наступает в пределах окна -within. Завершается с кодом exitError
+// если есть просроченные блоки.
+func runDue(args []string, stdout io.Writer, stderr io.Writer) int {
+	fset := flag.NewFlagSet("todolist due", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), `Использование: todolist due [флаги] [директория ...]
+
+Выводит блоки со сроком due:YYYY-MM-DD, которые просрочены или срок которых
+наступает в пределах окна, отсортированные по сроку.
+
+Флаги:`)
+		fset.PrintDefaults()
+		fmt.Fprintln(fset.Output(), `
+Коды завершения: 0 просроченных блоков нет, 1 есть просроченные блоки или
+ошибка вывода, 2 неверные аргументы.`)
+	}
+	sf := newScanFlags(fset)
+	sf.revFlag()
+	format := fset.String("format", "quickfix", "формат вывода: org, json, jsonl, sarif или quickfix")
+	within := fset.String("within", "0d", "окно срока, например, 14d, 2w или 36h")
+	now := fset.String("now", "", "текущая дата в формате YYYY-MM-DD, по умолчанию сегодня")
+	if err := fset.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	window, err := parseWithin(*within)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	day := time.Now()
+	if *now != "" {
+		if day, err = time.Parse(DueLayout, *now); err != nil {
+			fmt.Fprintln(stderr, "неверная дата -now:", *now)
+			return exitUsage
+		}
+	}
+	write, ok := Writers[*format]
+	if !ok {
+		fmt.Fprintln(stderr, "неизвестный формат вывода:", *format)
+		return exitUsage
+	}
+
+	roots, cfgs, code := sf.setup(fset.Args(), stderr)
+	if code != exitOK {
+		return code
+	}
+	defer sf.close(stderr)
+
+	todos, scanerrs := sf.scan(roots, cfgs, stderr)
+	due, overdue := DueTodos(todos, day, window)
+	if err := write(stdout, due); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	WriteErrorSummary(stderr, scanerrs)
+	if overdue > 0 {
+		fmt.Fprintf(stderr, "просрочено: %d, срок в пределах окна: %d\n", overdue, len(due)-overdue)
+		return exitError
+	}
+	return exitOK
+}
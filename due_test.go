@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test_Due тестирует отбор блоков по сроку. Блоки должны быть отсортированы по
+// сроку, блоки без срока и со сроком за окном пропускаться, а команда due
+// завершаться с кодом 1 если есть просроченные блоки.
+func Test_Due(t *testing.T) {
+	header := "сроки:"
+	for str, want := range map[string]time.Duration{"14d": 14 * 24 * time.Hour,
+		"2w": 14 * 24 * time.Hour, "36h": 36 * time.Hour} {
+		if got, err := parseWithin(str); err != nil || got != want {
+			t.Errorf("%s окно %s: требуется: %v, имеется: %v %v", header, str, want, got, err)
+		}
+	}
+	if _, err := parseWithin("-1d"); err == nil {
+		t.Errorf("%s окно -1d: нет ошибки", header)
+	}
+
+	dir := t.TempDir()
+	src := "// TODO(due:2027-01-01): later\n// TODO(p1, due:2026-10-20): soon\n" +
+		"// FIXME(bob, due:2026-10-01): late\n// TODO: no date\npackage x\n"
+	for name, data := range map[string]string{"go.mod": "module x\n", "x.go": src} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"due", "-no-cache", "-within", "14d", "-now", "2026-10-17", dir},
+		&stdout, &stderr)
+	if code != exitError {
+		t.Errorf("%s код завершения: требуется: %d, имеется: %d", header, exitError, code)
+	}
+	got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	want := []string{" late", " soon"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	for i := range want {
+		if !strings.HasSuffix(got[i], want[i]) {
+			t.Errorf("%s %d: требуется: …%s, имеется: %s", header, i, want[i], got[i])
+		}
+	}
+
+	stdout.Reset()
+	code = run([]string{"due", "-no-cache", "-now", "2026-09-01", dir}, &stdout, &stderr)
+	if code != exitOK || stdout.Len() != 0 {
+		t.Errorf("%s без просроченных: код %d, вывод %q", header, code, stdout.String())
+	}
+	if code := run([]string{"due", "-now", "01.09.2026", dir}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("%s неверная дата: требуется код %d, имеется: %d", header, exitUsage, code)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Виды ошибок поиска. Ошибка ScanError совпадает со своим видом при проверке
+// errors.Is.
+var (
+	ErrUnreadable   = errors.New("файл недоступен для чтения")
+	ErrBadPattern   = errors.New("неверный файловый шаблон")
+	ErrPermission   = errors.New("нет доступа")
+	ErrTokenTooLong = errors.New("слишком длинная строка")
+	ErrBadMeta      = errors.New("неверные сведения блока")
+)
+
+// errorKinds названия видов ошибок в отчёте
+var errorKinds = map[error]string{
+	ErrUnreadable:   "unreadable",
+	ErrBadPattern:   "pattern",
+	ErrPermission:   "permission",
+	ErrTokenTooLong: "too-long",
+	ErrBadMeta:      "meta",
+}
+
+// ScanError ошибка поиска связанная с файлом или директорией
+type ScanError struct {
+	Kind error  // вид ошибки, например, ErrPermission
+	Path string // путь к файлу или директории
+	Err  error  // исходная ошибка
+}
+
+// NewScanError возвращает ошибку поиска для пути, вид ошибки определяется по
+// исходной ошибке. Для nil возвращает nil, ошибка ScanError возвращается без
+// изменений.
+func NewScanError(path string, err error) error {
+	var se *ScanError
+	if err == nil || errors.As(err, &se) {
+		return err
+	}
+	kind := ErrUnreadable
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		kind = ErrPermission
+	case errors.Is(err, bufio.ErrTooLong):
+		kind = ErrTokenTooLong
+	case errors.Is(err, filepath.ErrBadPattern):
+		kind = ErrBadPattern
+	}
+	return &ScanError{kind, path, err}
+}
+
+func (e *ScanError) Error() string {
+	return e.Path + ": " + e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap возвращает исходную ошибку
+func (e *ScanError) Unwrap() error { return e.Err }
+
+// Is сравнивает вид ошибки
+func (e *ScanError) Is(target error) bool { return target == e.Kind }
+
+// Errors список ошибок возникших при поиске, который продолжается несмотря на
+// ошибки. Проверки errors.Is и errors.As выполняются для каждой ошибки
+// списка.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is проверяет совпадает ли с target хотя бы одна ошибка списка
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As находит первую ошибку списка подходящую для target
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorOrNil возвращает список ошибок как error или nil для пустого списка
+func (e Errors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// appendErrors добавляет ошибку в список, раскрывая списки Errors
+func appendErrors(list []error, err error) []error {
+	if err == nil {
+		return list
+	}
+	if errs, ok := err.(Errors); ok {
+		for _, e := range errs {
+			list = appendErrors(list, e)
+		}
+		return list
+	}
+	return append(list, err)
+}
+
+// ErrorKind возвращает название вида ошибки для отчёта
+func ErrorKind(err error) string {
+	for kind, name := range errorKinds {
+		if errors.Is(err, kind) {
+			return name
+		}
+	}
+	return "other"
+}
+
+// WriteErrorSummary выводит итог поиска: количество ошибок всего и по видам
+func WriteErrorSummary(w io.Writer, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	counts := map[string]int{}
+	for _, err := range errs {
+		counts[ErrorKind(err)]++
+	}
+	kinds := make([]string, 0, len(counts))
+	for kind, n := range counts {
+		kinds = append(kinds, fmt.Sprintf("%s: %d", kind, n))
+	}
+	sort.Strings(kinds)
+	fmt.Fprintf(w, "ошибок при поиске: %d (%s)\n", len(errs), strings.Join(kinds, ", "))
+}
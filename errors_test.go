@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// denyFS файловая система, которая запрещает доступ к указанным путям.
+// Реализует только fs.FS, поэтому все обращения проходят через Open.
+type denyFS struct {
+	files fstest.MapFS
+	deny  map[string]bool
+}
+
+func (d denyFS) Open(name string) (fs.File, error) {
+	if d.deny[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.files.Open(name)
+}
+
+// Test_Errors тестирует сбор ошибок при поиске. Недоступные файлы и
+// директории, слишком длинные строки и неверные шаблоны не должны прерывать
+// поиск, а возвращаться ошибками проверяемыми через errors.Is и errors.As.
+func Test_Errors(t *testing.T) {
+	header := "ошибки:"
+	fsd := denyFS{fstest.MapFS{
+		"src/hello/go.mod":       {},
+		"src/hello/main.c":       {Data: []byte("// TODO: found\n")},
+		"src/hello/long.c":       {Data: []byte("// " + strings.Repeat("x", 70000) + "\n")},
+		"src/hello/secret.c":     {Data: []byte("// TODO: secret\n")},
+		"src/hello/locked/lib.c": {Data: []byte("// TODO: locked\n")},
+		"src/locked/go.mod":      {},
+		"src/world/go.mod":       {},
+		"src/world/main.c":       {Data: []byte("// TODO: world\n")},
+	}, map[string]bool{"src/hello/secret.c": true, "src/hello/locked": true,
+		"src/locked": true}}
+
+	opt := DefaultScanOptions()
+	opt.markers = []string{"go.mod"}
+	todos, errs := Scan(fsd, "", "src", opt)
+
+	got := make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].position)
+	}
+	want := []string{"src/hello/main.c:1", "src/world/main.c:1"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	got = make([]string, 0)
+	for _, err := range errs {
+		var se *ScanError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s ошибка без пути: %v", header, err)
+		}
+		got = append(got, ErrorKind(err)+" "+se.Path)
+	}
+	want = []string{"permission src/locked", "permission src/hello/locked",
+		"too-long src/hello/long.c", "permission src/hello/secret.c"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+	if !errors.Is(errs[0], ErrPermission) || !errors.Is(errs[0], fs.ErrPermission) {
+		t.Errorf("%s errors.Is: %v", header, errs[0])
+	}
+
+	_, err := FindProjects(fsd, "src", []string{"["})
+	if !errors.Is(err, ErrBadPattern) {
+		t.Errorf("%s неверный шаблон: %v", header, err)
+	}
+
+	var b strings.Builder
+	WriteErrorSummary(&b, errs)
+	if want := "ошибок при поиске: 4 (permission: 3, too-long: 1)\n"; b.String() != want {
+		t.Errorf("%s итог: требуется: %q, имеется: %q", header, want, b.String())
+	}
+}
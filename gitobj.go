@@ -0,0 +1,851 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы объектов git, как они записываются в файле пакета
+const (
+	gitCommit   = 1
+	gitTree     = 2
+	gitBlob     = 3
+	gitTag      = 4
+	gitOfsDelta = 6
+	gitRefDelta = 7
+)
+
+// gitTypes названия типов объектов git
+var gitTypes = map[string]int{"commit": gitCommit, "tree": gitTree, "blob": gitBlob, "tag": gitTag}
+
+// gitHash идентификатор объекта git SHA-1
+type gitHash [20]byte
+
+func (h gitHash) String() string { return hex.EncodeToString(h[:]) }
+
+// parseGitHash разбирает полный идентификатор объекта из 40 шестнадцатеричных
+// символов
+func parseGitHash(str string) (gitHash, bool) {
+	var h gitHash
+	if len(str) != 2*len(h) {
+		return h, false
+	}
+	_, err := hex.Decode(h[:], []byte(str))
+	return h, err == nil
+}
+
+// gitRepo хранилище объектов репозитория git, которое читается напрямую из
+// файлов директории git: отдельных объектов и файлов пакетов. Безопасно для
+// одновременного использования.
+type gitRepo struct {
+	gitdir  string     // директория git: .git рабочей копии или bare репозиторий
+	common  string     // общая директория для ссылок и объектов рабочих копий
+	objects []string   // директории объектов, включая alternates
+	packs   []*gitPack // файлы пакетов всех директорий объектов
+}
+
+// gitPack файл пакета объектов и его индекс версии 2
+type gitPack struct {
+	file    *os.File
+	fanout  [256]uint32 // количество объектов с первым байтом не больше индекса
+	hashes  []byte      // отсортированные идентификаторы объектов
+	offsets []byte      // смещения объектов, 4 байта на объект
+	large   []byte      // смещения больше 2 ГиБ, 8 байт на смещение
+
+	mu    sync.Mutex
+	cache map[int64]gitObject // разобранные базовые объекты дельт по смещению
+}
+
+// gitObject прочитанный объект git
+type gitObject struct {
+	typ  int
+	data []byte
+}
+
+// isGitDir проверяет что директория является директорией git
+func isGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// findGitDir возвращает директорию git для директории dir и путь dir
+// относительно корня рабочей копии через «/». Директория git ищется в dir и
+// её родительских директориях, файл .git с записью gitdir: поддерживается.
+// Если dir сама является директорией git, то есть bare репозиторием, путь
+// пустой.
+func findGitDir(dir string) (string, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(filepath.Join(abs, ".git")); err != nil && isGitDir(abs) {
+		return abs, "", nil
+	}
+	for cur := abs; ; cur = filepath.Dir(cur) {
+		dotgit := filepath.Join(cur, ".git")
+		if info, err := os.Stat(dotgit); err == nil {
+			gitdir := dotgit
+			if !info.IsDir() {
+				data, err := os.ReadFile(dotgit)
+				if err != nil {
+					return "", "", err
+				}
+				line := strings.TrimSpace(string(data))
+				if !strings.HasPrefix(line, "gitdir:") {
+					return "", "", fmt.Errorf("%s: неверный файл .git", dotgit)
+				}
+				gitdir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+				if !filepath.IsAbs(gitdir) {
+					gitdir = filepath.Join(cur, gitdir)
+				}
+			}
+			prefix, err := filepath.Rel(cur, abs)
+			if err != nil {
+				return "", "", err
+			}
+			if prefix == "." {
+				prefix = ""
+			}
+			return gitdir, filepath.ToSlash(prefix), nil
+		}
+		if filepath.Dir(cur) == cur {
+			return "", "", fmt.Errorf("%s: не найден репозиторий git", dir)
+		}
+	}
+}
+
+// commonDir возвращает общую директорию git рабочей копии, созданной git
+// worktree, или саму директорию gitdir
+func commonDir(gitdir string) string {
+	data, err := os.ReadFile(filepath.Join(gitdir, "commondir"))
+	if err != nil {
+		return gitdir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitdir, common)
+	}
+	return common
+}
+
+// gitHead возвращает хэш коммита HEAD репозитория директории dir. Читаются
+// только ссылки, файлы пакетов не открываются. Если репозиторий не найден или
+// в нём ещё нет коммитов, второе значение равно false.
+func gitHead(dir string) (string, bool) {
+	gitdir, _, err := findGitDir(dir)
+	if err != nil {
+		return "", false
+	}
+	r := &gitRepo{gitdir: gitdir, common: commonDir(gitdir)}
+	id, ok := r.readRef("HEAD", 0)
+	if !ok {
+		return "", false
+	}
+	return id.String(), true
+}
+
+// openGitRepo открывает хранилище объектов директории git
+func openGitRepo(gitdir string) (*gitRepo, error) {
+	r := &gitRepo{gitdir: gitdir, common: commonDir(gitdir)}
+	if !isGitDir(r.common) {
+		return nil, fmt.Errorf("%s: не является директорией git", gitdir)
+	}
+	r.objects = []string{filepath.Join(r.common, "objects")}
+	data, _ := os.ReadFile(filepath.Join(r.objects[0], "info", "alternates"))
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(r.objects[0], line)
+			}
+			r.objects = append(r.objects, line)
+		}
+	}
+	for _, dir := range r.objects {
+		idxs, _ := filepath.Glob(filepath.Join(dir, "pack", "pack-*.idx"))
+		for _, idx := range idxs {
+			pack, err := openGitPack(idx)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			r.packs = append(r.packs, pack)
+		}
+	}
+	return r, nil
+}
+
+// Close закрывает файлы пакетов и возвращает первую ошибку закрытия
+func (r *gitRepo) Close() error {
+	var err error
+	for _, pack := range r.packs {
+		if e := pack.file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// openGitPack читает индекс версии 2 и открывает файл пакета
+func openGitPack(idx string) (*gitPack, error) {
+	data, err := os.ReadFile(idx)
+	if err != nil {
+		return nil, err
+	}
+	bad := fmt.Errorf("%s: неверный индекс пакета", idx)
+	if len(data) < 8+256*4 || !bytes.Equal(data[:8], []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}) {
+		return nil, bad
+	}
+	p := &gitPack{cache: map[int64]gitObject{}}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+	}
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(data) < pos+n*(20+4+4) {
+		return nil, bad
+	}
+	p.hashes = data[pos : pos+n*20]
+	pos += n * 20
+	pos += n * 4 // контрольные суммы crc32
+	p.offsets = data[pos : pos+n*4]
+	p.large = data[pos+n*4:]
+	if p.file, err = os.Open(strings.TrimSuffix(idx, ".idx") + ".pack"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// find возвращает смещение объекта в пакете
+func (p *gitPack) find(id gitHash) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i+1)*20], id[:]) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashes[i*20:(i+1)*20], id[:]) {
+		return 0, false
+	}
+	off := int64(binary.BigEndian.Uint32(p.offsets[i*4:]))
+	if off&0x80000000 != 0 {
+		idx := int(off&0x7fffffff) * 8
+		if idx+8 > len(p.large) {
+			return 0, false
+		}
+		off = int64(binary.BigEndian.Uint64(p.large[idx:]))
+	}
+	return off, true
+}
+
+// readVarint читает размер в формате дельты git: по 7 бит, младшие первыми
+func readVarint(br io.ByteReader) (int64, error) {
+	var size int64
+	for shift := uint(0); ; shift += 7 {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		size |= int64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return size, nil
+		}
+	}
+}
+
+// header читает заголовок объекта пакета по смещению off: тип, размер и
+// для дельт смещение или идентификатор базового объекта. Возвращает поток,
+// установленный на начало сжатых данных.
+func (p *gitPack) header(off int64) (typ int, size int64, base int64, ref gitHash, br *bufio.Reader, err error) {
+	br = bufio.NewReader(io.NewSectionReader(p.file, off, 1<<62))
+	c, err := br.ReadByte()
+	if err != nil {
+		return
+	}
+	typ, size = int(c>>4)&7, int64(c&15)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return
+		}
+		size |= int64(c&0x7f) << shift
+	}
+	switch typ {
+	case gitOfsDelta:
+		if c, err = br.ReadByte(); err != nil {
+			return
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return
+			}
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		base = off - rel
+	case gitRefDelta:
+		_, err = io.ReadFull(br, ref[:])
+	}
+	return
+}
+
+// maxPrealloc наибольший размер буфера, который выделяется заранее по размеру
+// из заголовка объекта или дельты. Размер в заголовке не проверен, буфер
+// большего размера растёт по мере чтения данных.
+const maxPrealloc = 1 << 20
+
+// inflate распаковывает size байт данных zlib из потока
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readSized(zr, size)
+}
+
+// readSized читает size байт из потока. Буфер растёт по мере чтения, поэтому
+// неверный размер из заголовка не приводит к выделению лишней памяти.
+func readSized(r io.Reader, size int64) ([]byte, error) {
+	if size < 0 {
+		return nil, errors.New("неверный размер объекта git")
+	}
+	var buf bytes.Buffer
+	if size <= maxPrealloc {
+		buf.Grow(int(size))
+	}
+	if _, err := io.CopyN(&buf, r, size); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// read читает объект пакета по смещению off, применяя дельты
+func (p *gitPack) read(r *gitRepo, off int64) (gitObject, error) {
+	p.mu.Lock()
+	obj, ok := p.cache[off]
+	p.mu.Unlock()
+	if ok {
+		return obj, nil
+	}
+	typ, size, base, ref, br, err := p.header(off)
+	if err != nil {
+		return gitObject{}, err
+	}
+	data, err := inflate(br, size)
+	if err != nil {
+		return gitObject{}, err
+	}
+	switch typ {
+	case gitCommit, gitTree, gitBlob, gitTag:
+		obj = gitObject{typ, data}
+	case gitOfsDelta, gitRefDelta:
+		var src gitObject
+		if typ == gitOfsDelta {
+			src, err = p.read(r, base)
+		} else {
+			src, err = r.read(ref)
+		}
+		if err != nil {
+			return gitObject{}, err
+		}
+		if data, err = applyDelta(src.data, data); err != nil {
+			return gitObject{}, err
+		}
+		obj = gitObject{src.typ, data}
+	default:
+		return gitObject{}, fmt.Errorf("неизвестный тип объекта %d", typ)
+	}
+	// объекты цепочки дельт служат базой следующих дельт той же цепочки
+	p.mu.Lock()
+	if len(p.cache) > 256 {
+		p.cache = map[int64]gitObject{}
+	}
+	p.cache[off] = obj
+	p.mu.Unlock()
+	return obj, nil
+}
+
+// applyDelta применяет дельту git к базовому объекту
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	bad := errors.New("неверная дельта объекта git")
+	br := bytes.NewReader(delta)
+	srcSize, err := readVarint(br)
+	if err != nil || srcSize != int64(len(base)) {
+		return nil, bad
+	}
+	dstSize, err := readVarint(br)
+	if err != nil || dstSize < 0 {
+		return nil, bad
+	}
+	prealloc := dstSize
+	if prealloc > maxPrealloc {
+		prealloc = maxPrealloc
+	}
+	result := make([]byte, 0, prealloc)
+	for br.Len() > 0 {
+		op, _ := br.ReadByte()
+		switch {
+		case op&0x80 != 0:
+			// копирование из базового объекта, байты смещения и размера
+			// присутствуют если установлены соответствующие биты op
+			var off, size int64
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				c, err := br.ReadByte()
+				if err != nil {
+					return nil, bad
+				}
+				if i < 4 {
+					off |= int64(c) << (8 * i)
+				} else {
+					size |= int64(c) << (8 * (i - 4))
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if off+size > int64(len(base)) || int64(len(result))+size > dstSize {
+				return nil, bad
+			}
+			result = append(result, base[off:off+size]...)
+		case op != 0:
+			// вставка следующих op байт дельты
+			chunk := make([]byte, op)
+			if _, err := io.ReadFull(br, chunk); err != nil ||
+				int64(len(result)+len(chunk)) > dstSize {
+				return nil, bad
+			}
+			result = append(result, chunk...)
+		default:
+			return nil, bad
+		}
+	}
+	if int64(len(result)) != dstSize {
+		return nil, bad
+	}
+	return result, nil
+}
+
+// loosePath возвращает путь к отдельному объекту в директории объектов
+func loosePath(dir string, id gitHash) string {
+	str := id.String()
+	return filepath.Join(dir, str[:2], str[2:])
+}
+
+// looseObject открытый отдельный объект, поток установлен на начало данных
+// после заголовка
+type looseObject struct {
+	typ  int           // тип объекта
+	size int64         // размер данных из заголовка
+	br   *bufio.Reader // распакованные данные объекта
+	zr   io.ReadCloser
+	file *os.File
+}
+
+// Close закрывает поток zlib и файл объекта
+func (lo *looseObject) Close() error {
+	lo.zr.Close()
+	return lo.file.Close()
+}
+
+// openLoose открывает отдельный объект: данные zlib с заголовком «тип
+// размер\0». Читается только заголовок, данные объекта остаются в потоке.
+func openLoose(file string) (*looseObject, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	lo := &looseObject{br: bufio.NewReader(zr), zr: zr, file: f}
+	// заголовок: название типа, пробел и десятичный размер
+	header := make([]byte, 0, 32)
+	for {
+		c, err := lo.br.ReadByte()
+		if err != nil || len(header) == cap(header) {
+			lo.Close()
+			return nil, fmt.Errorf("%s: неверный заголовок объекта", file)
+		}
+		if c == 0 {
+			break
+		}
+		header = append(header, c)
+	}
+	fields := strings.Fields(string(header))
+	if len(fields) != 2 || gitTypes[fields[0]] == 0 {
+		lo.Close()
+		return nil, fmt.Errorf("%s: неверный заголовок объекта", file)
+	}
+	if lo.size, err = strconv.ParseInt(fields[1], 10, 64); err != nil || lo.size < 0 {
+		lo.Close()
+		return nil, fmt.Errorf("%s: неверный размер объекта", file)
+	}
+	lo.typ = gitTypes[fields[0]]
+	return lo, nil
+}
+
+// readLoose читает отдельный объект. Читается не больше байт, чем указано в
+// заголовке объекта.
+func readLoose(file string) (gitObject, error) {
+	lo, err := openLoose(file)
+	if err != nil {
+		return gitObject{}, err
+	}
+	defer lo.Close()
+	data, err := readSized(lo.br, lo.size)
+	if err != nil {
+		return gitObject{}, fmt.Errorf("%s: неверный размер объекта: %w", file, err)
+	}
+	if _, err := lo.br.ReadByte(); err != io.EOF {
+		return gitObject{}, fmt.Errorf("%s: неверный размер объекта", file)
+	}
+	return gitObject{lo.typ, data}, nil
+}
+
+// read возвращает объект по идентификатору из отдельных объектов или пакетов
+func (r *gitRepo) read(id gitHash) (gitObject, error) {
+	for _, dir := range r.objects {
+		obj, err := readLoose(loosePath(dir, id))
+		if err == nil {
+			return obj, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return gitObject{}, err
+		}
+	}
+	for _, pack := range r.packs {
+		if off, ok := pack.find(id); ok {
+			obj, err := pack.read(r, off)
+			if err != nil {
+				return gitObject{}, fmt.Errorf("объект %s: %w", id, err)
+			}
+			return obj, nil
+		}
+	}
+	return gitObject{}, fmt.Errorf("объект %s не найден", id)
+}
+
+// readType возвращает объект указанного типа. Теги раскрываются до объекта
+// на который они указывают, коммит раскрывается до дерева если требуется
+// дерево.
+func (r *gitRepo) readType(id gitHash, typ int) (gitHash, gitObject, error) {
+	for {
+		obj, err := r.read(id)
+		if err != nil {
+			return id, obj, err
+		}
+		if obj.typ == typ {
+			return id, obj, nil
+		}
+		var field string
+		switch {
+		case obj.typ == gitTag:
+			field = "object"
+		case obj.typ == gitCommit && typ == gitTree:
+			field = "tree"
+		default:
+			return id, obj, fmt.Errorf("объект %s не является %s", id, typeName(typ))
+		}
+		next, ok := headerField(obj.data, field)
+		if !ok {
+			return id, obj, fmt.Errorf("объект %s: нет поля %s", id, field)
+		}
+		id = next
+	}
+}
+
+// typeName возвращает название типа объекта
+func typeName(typ int) string {
+	for name, t := range gitTypes {
+		if t == typ {
+			return name
+		}
+	}
+	return strconv.Itoa(typ)
+}
+
+// headerFields возвращает идентификаторы из строк заголовка коммита или тега,
+// которые начинаются с name, например, parent
+func headerFields(data []byte, name string) []gitHash {
+	result := make([]gitHash, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break // конец заголовка
+		}
+		if strings.HasPrefix(line, name+" ") {
+			if id, ok := parseGitHash(line[len(name)+1:]); ok {
+				result = append(result, id)
+			}
+		}
+	}
+	return result
+}
+
+// headerField возвращает первый идентификатор из строки заголовка name
+func headerField(data []byte, name string) (gitHash, bool) {
+	list := headerFields(data, name)
+	if len(list) == 0 {
+		return gitHash{}, false
+	}
+	return list[0], true
+}
+
+// readRef возвращает идентификатор объекта ссылки, раскрывая символические
+// ссылки. Ссылки ищутся в файлах и в packed-refs.
+func (r *gitRepo) readRef(name string, depth int) (gitHash, bool) {
+	if depth > 8 {
+		return gitHash{}, false
+	}
+	dir := r.common
+	if !strings.HasPrefix(name, "refs/") {
+		dir = r.gitdir // HEAD и другие ссылки рабочей копии
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+		line := strings.TrimSpace(string(data))
+		if strings.HasPrefix(line, "ref:") {
+			return r.readRef(strings.TrimSpace(line[4:]), depth+1)
+		}
+		return parseGitHash(line)
+	}
+	data, _ := os.ReadFile(filepath.Join(r.common, "packed-refs"))
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] == name {
+			return parseGitHash(fields[0])
+		}
+	}
+	return gitHash{}, false
+}
+
+// findPrefix возвращает объект по сокращённому идентификатору
+func (r *gitRepo) findPrefix(prefix string) (gitHash, error) {
+	found := map[gitHash]bool{}
+	for _, dir := range r.objects {
+		names, _ := filepath.Glob(filepath.Join(dir, prefix[:2], prefix[2:]+"*"))
+		for _, name := range names {
+			if id, ok := parseGitHash(prefix[:2] + filepath.Base(name)); ok {
+				found[id] = true
+			}
+		}
+	}
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return gitHash{}, err
+	}
+	for _, pack := range r.packs {
+		lo := 0
+		if first > 0 {
+			lo = int(pack.fanout[first-1])
+		}
+		for i := lo; i < int(pack.fanout[first]); i++ {
+			str := hex.EncodeToString(pack.hashes[i*20 : (i+1)*20])
+			if strings.HasPrefix(str, prefix) {
+				id, _ := parseGitHash(str)
+				found[id] = true
+			}
+		}
+	}
+	if len(found) != 1 {
+		return gitHash{}, fmt.Errorf("ревизия %s не найдена или неоднозначна", prefix)
+	}
+	for id := range found {
+		return id, nil
+	}
+	return gitHash{}, nil
+}
+
+// resolve возвращает объект ревизии. Ревизия задаётся идентификатором,
+// сокращённым идентификатором не короче 4 символов, HEAD, названием ветки,
+// тега или ссылки с суффиксами ~N, ^N и ^{} как в git rev-parse.
+func (r *gitRepo) resolve(rev string) (gitHash, error) {
+	name, ops := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		name, ops = rev[:i], rev[i:]
+	}
+	id, ok := parseGitHash(strings.ToLower(name))
+	for _, ref := range []string{name, "refs/" + name, "refs/tags/" + name,
+		"refs/heads/" + name, "refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"} {
+		if ok || name == "" {
+			break
+		}
+		id, ok = r.readRef(ref, 0)
+	}
+	if !ok {
+		if len(name) < 4 || strings.Trim(strings.ToLower(name), "0123456789abcdef") != "" {
+			return id, fmt.Errorf("ревизия %s не найдена", rev)
+		}
+		var err error
+		if id, err = r.findPrefix(strings.ToLower(name)); err != nil {
+			return id, err
+		}
+	}
+
+	for ops != "" {
+		op := ops[0]
+		ops = ops[1:]
+		if op == '^' && strings.HasPrefix(ops, "{") {
+			end := strings.IndexByte(ops, '}')
+			if end < 0 {
+				return id, fmt.Errorf("неверная ревизия %s", rev)
+			}
+			typ := gitTypes[ops[1:end]]
+			if ops[1:end] == "" {
+				typ = gitCommit
+			}
+			if typ == 0 {
+				return id, fmt.Errorf("неверная ревизия %s", rev)
+			}
+			ops = ops[end+1:]
+			var err error
+			if id, _, err = r.readType(id, typ); err != nil {
+				return id, err
+			}
+			continue
+		}
+		digits := len(ops) - len(strings.TrimLeft(ops, "0123456789"))
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(ops[:digits])
+			ops = ops[digits:]
+		}
+		// ~N переходит N раз к первому родителю, ^N к N-ому родителю
+		steps, parent := n, 1
+		if op == '^' {
+			steps, parent = 1, n
+		}
+		for i := 0; i < steps && parent > 0; i++ {
+			cid, obj, err := r.readType(id, gitCommit)
+			if err != nil {
+				return cid, err
+			}
+			parents := headerFields(obj.data, "parent")
+			if len(parents) < parent {
+				return id, fmt.Errorf("у ревизии %s нет родителя %d", rev, parent)
+			}
+			id = parents[parent-1]
+		}
+	}
+	return id, nil
+}
+
+// objectSize возвращает размер объекта по его заголовку, не распаковывая
+// данные. Для дельт в пакете читается только начало дельты.
+func (r *gitRepo) objectSize(id gitHash) (int64, error) {
+	for _, pack := range r.packs {
+		off, ok := pack.find(id)
+		if !ok {
+			continue
+		}
+		typ, size, _, _, br, err := pack.header(off)
+		if err != nil || (typ != gitOfsDelta && typ != gitRefDelta) {
+			return size, err
+		}
+		// размер результата записан в начале дельты после размера базы
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		hr := bufio.NewReader(zr)
+		if _, err := readVarint(hr); err != nil {
+			return 0, err
+		}
+		return readVarint(hr)
+	}
+	// для отдельного объекта достаточно заголовка
+	for _, dir := range r.objects {
+		lo, err := openLoose(loosePath(dir, id))
+		if err == nil {
+			lo.Close()
+			return lo.size, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("объект %s не найден", id)
+}
+
+// treeFS возвращает файловую систему с деревом ревизии id. Содержимое файлов
+// читается при открытии. Подмодули и символические ссылки пропускаются.
+func (r *gitRepo) treeFS(id gitHash) (*memFS, error) {
+	mfs := newMemFS()
+	return mfs, r.walkTree(mfs, id, "")
+}
+
+// walkTree добавляет в файловую систему файлы дерева id с путём dir
+func (r *gitRepo) walkTree(mfs *memFS, id gitHash, dir string) error {
+	_, obj, err := r.readType(id, gitTree)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		mfs.mkdir(dir)
+	}
+	data := obj.data
+	for len(data) > 0 {
+		// запись дерева: «режим имя\0» и 20 байт идентификатора
+		nul := bytes.IndexByte(data, 0)
+		if nul < 0 || nul+21 > len(data) {
+			return fmt.Errorf("дерево %s: неверная запись", id)
+		}
+		sp := bytes.IndexByte(data[:nul], ' ')
+		if sp < 0 {
+			return fmt.Errorf("дерево %s: неверная запись", id)
+		}
+		mode, name := string(data[:sp]), string(data[sp+1:nul])
+		var child gitHash
+		copy(child[:], data[nul+1:nul+21])
+		data = data[nul+21:]
+
+		file := name
+		if dir != "" {
+			file = dir + "/" + name
+		}
+		switch mode {
+		case "40000", "040000":
+			if err := r.walkTree(mfs, child, file); err != nil {
+				return err
+			}
+		case "100644", "100755", "100664":
+			size, err := r.objectSize(child)
+			if err != nil {
+				return err
+			}
+			blob := child
+			mfs.addFile(file, size, time.Time{}, func() ([]byte, error) {
+				obj, err := r.read(blob)
+				return obj.data, err
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test_ApplyDelta тестирует применение дельты git: копирование частей
+// базового объекта и вставку новых данных.
+func Test_ApplyDelta(t *testing.T) {
+	base := []byte("hello, world")
+	// размер базы 12, размер результата 16, копирование 5 байт со смещения 0,
+	// вставка « big», копирование 7 байт со смещения 5
+	delta := []byte{12, 16, 0x90, 5, 4, ' ', 'b', 'i', 'g', 0x91, 5, 7}
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello big, world"; string(got) != want {
+		t.Errorf("дельта: требуется: %q, имеется: %q", want, got)
+	}
+	if _, err := applyDelta(base, []byte{11, 1, 0x90, 1}); err == nil {
+		t.Errorf("дельта: нет ошибки для неверного размера базы")
+	}
+	// размер результата меньше скопированных данных
+	if _, err := applyDelta(base, []byte{12, 4, 0x90, 5}); err == nil {
+		t.Errorf("дельта: нет ошибки для результата больше заявленного")
+	}
+	// огромный размер результата не выделяется заранее
+	huge := []byte{12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x90, 5}
+	if _, err := applyDelta(base, huge); err == nil {
+		t.Errorf("дельта: нет ошибки для неверного размера результата")
+	}
+}
+
+// Test_Inflate тестирует распаковку данных объекта: размер из заголовка не
+// выделяется заранее, поэтому огромный размер приводит к ошибке, а не к
+// выделению памяти.
+func Test_Inflate(t *testing.T) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	data, err := inflate(bytes.NewReader(b.Bytes()), 5)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("распаковка: требуется: hello, имеется: %q %v", data, err)
+	}
+	if _, err := inflate(bytes.NewReader(b.Bytes()), 1<<60); err == nil {
+		t.Errorf("распаковка: нет ошибки для размера больше данных")
+	}
+}
+
+// Test_ReadLoose тестирует чтение отдельного объекта git. Размер объекта
+// берётся из заголовка без распаковки данных, а объект с размером в
+// заголовке, который не совпадает с данными, считается неверным.
+func Test_ReadLoose(t *testing.T) {
+	header := "отдельный объект:"
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		t.Helper()
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		zw.Write([]byte(data))
+		zw.Close()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	obj, err := readLoose(write("ok", "blob 5\x00hello"))
+	if err != nil || obj.typ != gitBlob || string(obj.data) != "hello" {
+		t.Errorf("%s требуется: hello, имеется: %q %v", header, obj.data, err)
+	}
+	lo, err := openLoose(write("huge", "blob 1152921504606846976\x00hello"))
+	if err != nil || lo.size != 1<<60 {
+		t.Fatalf("%s заголовок: %v %v", header, lo, err)
+	}
+	lo.Close()
+	for _, file := range []string{lo.file.Name(), write("long", "blob 3\x00hello")} {
+		if _, err := readLoose(file); err == nil {
+			t.Errorf("%s %s: нет ошибки для неверного размера", header, file)
+		}
+	}
+	if _, err := openLoose(write("bad", "blob")); err == nil {
+		t.Errorf("%s нет ошибки для заголовка без нулевого байта", header)
+	}
+}
+
+// Test_GitRevFS тестирует чтение ревизий напрямую из директории git. В
+// временной директории создаются два коммита и аннотированный тег, объекты
+// упаковываются, после чего создаётся третий коммит отдельными объектами.
+// Ревизии должны читаться из пакета и отдельных объектов, в том числе из
+// bare репозитория, а поиск с флагом -rev находить блоки ревизии.
+func Test_GitRevFS(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	header := "ревизия git:"
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Tester", "-c", "user.email=tester@example.com"},
+			args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out), err)
+		}
+	}
+	commit := func(msg string, files map[string]string) {
+		t.Helper()
+		for name, data := range files {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", msg)
+	}
+	long := strings.Repeat("// filler line for delta compression\n", 50)
+	git("init", "-q")
+	commit("one", map[string]string{"go.mod": "module x\n",
+		"lib/lib.go": "package lib\n" + long + "// TODO: first\n"})
+	git("tag", "-a", "-m", "release", "v1.0.0")
+	commit("two", map[string]string{"lib/lib.go": "package lib\n" + long + "// TODO: second\n"})
+	git("gc", "-q", "--aggressive")
+	commit("three", map[string]string{"lib/lib.go": "package lib\n" + long + "// TODO: third\n"})
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	if out, err := exec.Command("git", "clone", "-q", "--bare", dir, bare).CombinedOutput(); err != nil {
+		t.Fatal(string(out), err)
+	}
+
+	want := map[string]string{"HEAD": "third", "HEAD~1": "second", "HEAD^^": "first",
+		"v1.0.0": "first", "v1.0.0^{}": "first", "HEAD~2^{tree}": "first"}
+	for _, root := range []string{dir, bare} {
+		for rev, text := range want {
+			rfs, err := GitRevFS(root, rev)
+			if err != nil {
+				t.Errorf("%s %s: %v", header, rev, err)
+				continue
+			}
+			data, err := fs.ReadFile(rfs, "lib/lib.go")
+			if err != nil || !strings.HasSuffix(string(data), "// TODO: "+text+"\n") {
+				t.Errorf("%s %s: требуется: %s, имеется: %d байт %v", header, rev, text,
+					len(data), err)
+			}
+			if err := rfs.Close(); err != nil {
+				t.Errorf("%s %s: закрытие: %v", header, rev, err)
+			}
+		}
+	}
+
+	rfs, err := GitRevFS(filepath.Join(dir, "lib"), "HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(rfs, "lib.go"); err != nil {
+		t.Errorf("%s поддиректория: %v", header, err)
+	}
+	if len(rfs.repo.packs) == 0 {
+		t.Errorf("%s нет файлов пакетов", header)
+	}
+	rfs.Close()
+	for _, pack := range rfs.repo.packs {
+		if _, err := pack.file.Stat(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s файл пакета не закрыт: %v", header, err)
+		}
+	}
+	for _, rev := range []string{"missing", "HEAD~5", "v1.0.0^{blob}"} {
+		if _, err := GitRevFS(dir, rev); err == nil {
+			t.Errorf("%s %s: нет ошибки", header, rev)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"-no-cache", "-format", "quickfix", "-rev", "v1.0.0", bare},
+		&stdout, &stderr)
+	if code != exitOK || !strings.HasSuffix(strings.TrimSpace(stdout.String()), "lib.go:52:1: TODO: first") {
+		t.Errorf("%s -rev: код %d, вывод %q, %s", header, code, stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// gitOutput выполняет команду git в директории dir и возвращает её вывод.
+// Текст ошибки включает сообщение git.
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
+
+// GitRevFS возвращает файловую систему с деревом ревизии rev репозитория git,
+// в котором находится директория dir, или bare репозитория dir. Объекты
+// читаются напрямую из директории git, как отдельные объекты, так и из файлов
+// пакетов, рабочая копия не используется. Пути в файловой системе
+// указываются относительно dir. В корне репозитория есть пустая директория
+// .git, как в рабочей копии, чтобы маркер проекта .git находил те же проекты.
+// Подмодули и символические ссылки пропускаются. Файлы пакетов остаются
+// открытыми, пока файловая система не закрыта вызовом Close.
+func GitRevFS(dir string, rev string) (*RevFS, error) {
+	gitdir, prefix, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := openGitRepo(gitdir)
+	if err != nil {
+		return nil, err
+	}
+	id, err := repo.resolve(rev)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	mfs, err := repo.treeFS(id)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	mfs.mkdir(".git")
+	rfs := &RevFS{mfs, repo}
+	if prefix != "" {
+		if rfs.FS, err = fs.Sub(mfs, prefix); err != nil {
+			repo.Close()
+			return nil, err
+		}
+	}
+	return rfs, nil
+}
+
+// RevFS файловая система с деревом ревизии git. Содержимое файлов читается из
+// файлов пакетов репозитория, которые закрываются вызовом Close.
+type RevFS struct {
+	fs.FS
+	repo *gitRepo
+}
+
+// Close закрывает файлы пакетов репозитория. Реализует интерфейс io.Closer.
+func (r *RevFS) Close() error { return r.repo.Close() }
@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"strings"
+)
+
+// findGoComments возвращает список комментариев Go файла. Комментарии
+// находятся стандартным go/scanner, поэтому границы комментариев, строки и raw
+// строки определяются точно. Много строчный комментарий разбивается на строки
+// файла, несколько комментариев в одной строке объединяются.
+func findGoComments(fsd fs.FS, file string) ([]CommentLine, error) {
+	result := make([]CommentLine, 0)
+	src, err := fs.ReadFile(fsd, file)
+	if err != nil {
+		return result, NewScanError(file, err)
+	}
+
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile(file, -1, len(src)), src, nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.COMMENT {
+			continue
+		}
+		p := fset.Position(pos)
+		if strings.HasPrefix(lit, "//") {
+			result = appendComment(result, CommentLine{p.Line, p.Column, lit[2:]})
+			continue
+		}
+		// не закрытый в конце файла комментарий go/scanner возвращает без «*/»
+		text := lit[2:]
+		if len(lit) >= 4 && strings.HasSuffix(lit, "*/") {
+			text = lit[2 : len(lit)-2]
+		}
+		body := strings.Split(text, "\n")
+		for i := range body {
+			col := 1
+			if i == 0 {
+				col = p.Column
+			}
+			result = appendComment(result, CommentLine{p.Line + i, col, body[i]})
+		}
+	}
+	return result, nil
+}
+
+// appendComment добавляет строку комментария в список, объединяя её с
+// последней строкой списка если обе находятся в одной строке файла.
+func appendComment(list []CommentLine, cl CommentLine) []CommentLine {
+	if last := len(list) - 1; last > -1 && list[last].line == cl.line {
+		list[last].data += cl.data
+		return list
+	}
+	return append(list, cl)
+}
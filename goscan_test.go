@@ -0,0 +1,36 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// Test_GoCommentsUnterminated тестирует поиск комментариев в Go файле, который
+// заканчивается не закрытым много строчным комментарием, например, при
+// сохранении файла во время редактирования. Текст комментария должен
+// сохраняться полностью, без паники на коротких комментариях.
+func Test_GoCommentsUnterminated(t *testing.T) {
+	header := "не закрытый комментарий go:"
+	want := map[string]string{
+		"package x\n/*":                 "",
+		"package x\n/*a":                "a",
+		"package x\n/* TODO: x":         " TODO: x",
+		"package x\n/* TODO: closed */": " TODO: closed ",
+	}
+	for src, text := range want {
+		fsd := fstest.MapFS{"x.go": {Data: []byte(src)}}
+		got, err := findGoComments(fsd, "x.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if guardLenght(t, header, 1, len(got)) {
+			t.Fatal(got)
+		}
+		if got[0].line != 2 || got[0].data != text {
+			t.Errorf("%s %q: требуется: %q, имеется: %v", header, src, text, got[0])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// parseGoWork возвращает пути модулей из директив use файла go.work в том
+// виде как они записаны в файле. Поддерживаются одиночные директивы и блоки
+// use ( ... ), комментарии «//» игнорируются.
+func parseGoWork(data string) []string {
+	result := make([]string, 0)
+	block := false
+	for _, line := range strings.Split(data, "\n") {
+		if idx := strings.Index(line, "//"); idx > -1 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case block && line == ")":
+			block = false
+			continue
+		case block:
+		case strings.HasPrefix(line, "use ") || strings.HasPrefix(line, "use\t"):
+			line = strings.TrimSpace(line[len("use"):])
+			if line == "(" {
+				block = true
+				continue
+			}
+		default:
+			continue
+		}
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// goWorkModules возвращает директории модулей рабочего пространства go.work
+// находящегося в директории dir. Модули вне dir и не существующие
+// директории пропускаются.
+func goWorkModules(fsd fs.FS, dir string) []string {
+	data, err := fs.ReadFile(fsd, path.Join(dir, "go.work"))
+	if err != nil {
+		return nil
+	}
+	result := make([]string, 0)
+	for _, use := range parseGoWork(string(data)) {
+		if path.IsAbs(use) {
+			continue
+		}
+		module := path.Join(dir, use)
+		if module == dir {
+			continue
+		}
+		if _, ok := relTo(dir, module); !ok {
+			continue
+		}
+		if info, err := fs.Stat(fsd, module); err == nil && info.IsDir() {
+			result = append(result, module)
+		}
+	}
+	return result
+}
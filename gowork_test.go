@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// Test_GoWork тестирует разбор директив use файла go.work
+func Test_GoWork(t *testing.T) {
+	header := "go.work:"
+	got := parseGoWork(`go 1.18
+
+use ./api // основной модуль
+use (
+	.
+	./tools
+	"./with space"
+)
+`)
+	want := []string{"./api", ".", "./tools", "./with space"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
+
+// Test_NestedProjects тестирует поиск вложенных проектов монорепозитория.
+// Без поиска вложенных проектов должен быть найден корень и модули
+// перечисленные в go.work, с поиском и остальные вложенные проекты. Каждый
+// файл должен относиться только к ближайшему проекту.
+func Test_NestedProjects(t *testing.T) {
+	header := "вложенные проекты:"
+	fsd := fstest.MapFS{
+		"repo/Makefile":         {},
+		"repo/go.work":          {Data: []byte("use (\n\t.\n\t./api\n\t../outside\n)\n")},
+		"repo/main.go":          {Data: []byte("// TODO: root\n")},
+		"repo/api/go.mod":       {},
+		"repo/api/api.go":       {Data: []byte("// TODO: api\n")},
+		"repo/tools/go.mod":     {},
+		"repo/tools/gen/gen.go": {Data: []byte("// TODO: tools\n")},
+	}
+
+	got, err := FindProjects(fsd, ".", []string{"go.mod", "Makefile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"repo", "repo/api"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	opt := DefaultScanOptions()
+	opt.markers = []string{"go.mod", "Makefile"}
+	opt.nested = true
+	todos, errs := Scan(fsd, "", ".", opt)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	got = make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].project+" "+todos[i].position)
+	}
+	want = []string{"repo repo/main.go:1", "repo/api repo/api/api.go:1",
+		"repo/tools repo/tools/gen/gen.go:1"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
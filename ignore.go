@@ -0,0 +1,192 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// IgnoreFiles имена файлов с правилами исключения. Файл .todoignore имеет тот
+// же синтаксис что и .gitignore и позволяет исключить файлы только из поиска
+// блоков комментариев.
+var IgnoreFiles = []string{".gitignore", ".todoignore"}
+
+// ignoreRule правило исключения из файла .gitignore
+type ignoreRule struct {
+	base     string   // директория файла с правилом
+	segments []string // части шаблона разделённые «/»
+	negate   bool     // правило начинается с «!» и возвращает файл
+	dirOnly  bool     // правило заканчивается «/» и применяется к директориям
+	anchored bool     // шаблон содержит «/» и отсчитывается от base
+}
+
+// Ignore набор правил исключения действующих в директории: правила файлов
+// исключения самой директории и всех родительских директорий, начиная с
+// корня поиска. Правила применяются по порядку, последнее совпавшее правило
+// определяет исключён ли файл.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// parseIgnoreRule разбирает строку файла исключений. Возвращает false для
+// пустых строк и комментариев.
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	// завершающие пробелы игнорируются, если не экранированы
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// relTo возвращает путь p относительно директории base. Второе значение
+// равно false если p не находится внутри base.
+func relTo(base string, p string) (string, bool) {
+	if base == "." || base == "" {
+		return p, p != "." && p != ""
+	}
+	if !strings.HasPrefix(p, base+"/") {
+		return "", false
+	}
+	return p[len(base)+1:], true
+}
+
+// matchSegments сравнивает части пути с частями шаблона. Часть шаблона «**»
+// совпадает с любым количеством частей пути, в том числе с нулевым.
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// match проверяет совпадение правила с путём
+func (rule ignoreRule) match(p string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	rel, ok := relTo(rule.base, p)
+	if !ok {
+		return false
+	}
+	if !rule.anchored {
+		ok, err := path.Match(rule.segments[0], path.Base(rel))
+		return err == nil && ok
+	}
+	return matchSegments(rule.segments, strings.Split(rel, "/"))
+}
+
+// Match проверяет исключён ли файл или директория по пути p
+func (ig *Ignore) Match(p string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.match(p, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// IgnoreFor возвращает набор правил действующих в родительской директории dir
+// при обходе начиная с директории root: правила файлов исключения root и всех
+// директорий между root и dir.
+func IgnoreFor(fsd fs.FS, root string, dir string) *Ignore {
+	rel, ok := relTo(root, dir)
+	if !ok {
+		return nil
+	}
+	parts := strings.Split(rel, "/")
+	ig := (*Ignore)(nil).Load(fsd, root)
+	for _, part := range parts[:len(parts)-1] {
+		root = path.Join(root, part)
+		ig = ig.Load(fsd, root)
+	}
+	return ig
+}
+
+// With возвращает набор правил ig, дополненный правилами patterns в синтаксисе
+// .gitignore, которые отсчитываются от директории base.
+func (ig *Ignore) With(base string, patterns []string) *Ignore {
+	if len(patterns) == 0 {
+		return ig
+	}
+	result := &Ignore{}
+	if ig != nil {
+		result.rules = ig.rules[:len(ig.rules):len(ig.rules)]
+	}
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule(base, pattern); ok {
+			result.rules = append(result.rules, rule)
+		}
+	}
+	return result
+}
+
+// Load возвращает набор правил для директории dir: правила ig, дополненные
+// правилами файлов исключения найденных в dir. Исходный набор не изменяется.
+func (ig *Ignore) Load(fsd fs.FS, dir string) *Ignore {
+	result := &Ignore{}
+	if ig != nil {
+		result.rules = ig.rules[:len(ig.rules):len(ig.rules)]
+	}
+	for _, name := range IgnoreFiles {
+		file, err := fsd.Open(path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+				result.rules = append(result.rules, rule)
+			}
+		}
+		file.Close()
+	}
+	return result
+}
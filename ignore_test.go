@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// Test_IgnoreRules тестирует сопоставление путей правилам .gitignore:
+// шаблоны без «/» совпадают с именем на любой глубине, шаблоны с «/»
+// отсчитываются от директории файла исключений, «**» совпадает с любым
+// количеством директорий, «/» в конце ограничивает правило директориями, а
+// «!» возвращает исключённый ранее файл.
+func Test_IgnoreRules(t *testing.T) {
+	ig := &Ignore{}
+	for _, line := range []string{"# комментарий", "", "*.log", "!keep.log",
+		"/build", "docs/**/gen", "tmp/", "**/cache/*.go", "a/**"} {
+		if rule, ok := parseIgnoreRule("prj", line); ok {
+			ig.rules = append(ig.rules, rule)
+		}
+	}
+	data := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"prj/x/app.log", false, true},
+		{"prj/x/keep.log", false, false},
+		{"prj/build", true, true},
+		{"prj/x/build", true, false},
+		{"prj/docs/gen", true, true},
+		{"prj/docs/a/b/gen", false, true},
+		{"prj/x/tmp", true, true},
+		{"prj/x/tmp", false, false},
+		{"prj/cache/c.go", false, true},
+		{"prj/x/y/cache/c.go", false, true},
+		{"prj/a", true, false},
+		{"prj/a/b.go", false, true},
+		{"other/app.log", false, false},
+	}
+	for _, d := range data {
+		if got := ig.Match(d.path, d.isDir); got != d.want {
+			t.Errorf("исключение %s: требуется: %v, имеется: %v", d.path, d.want, got)
+		}
+	}
+}
+
+// Test_IgnoreFiles тестирует применение вложенных файлов .gitignore и файла
+// .todoignore при поиске проектов и файлов.
+func Test_IgnoreFiles(t *testing.T) {
+	header := "исключение файлов:"
+	fsd := fstest.MapFS{
+		"src/.gitignore":            {Data: []byte("vendor/\n*.gen.go\n")},
+		"src/vendor/dep/go.mod":     {},
+		"src/app/go.mod":            {},
+		"src/app/.todoignore":       {Data: []byte("/testdata\n")},
+		"src/app/main.go":           {},
+		"src/app/api.gen.go":        {},
+		"src/app/testdata/x.go":     {},
+		"src/app/lib/.gitignore":    {Data: []byte("*.go\n!lib.go\n")},
+		"src/app/lib/lib.go":        {},
+		"src/app/lib/other.go":      {},
+		"src/app/build/.gitignore":  {Data: []byte("*\n")},
+		"src/app/build/out/main.go": {},
+	}
+
+	got, err := FindProjects(fsd, "src", []string{"go.mod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"src/app"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	got, err = FindFiles(fsd, "src/app", []string{"*.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"src/app/api.gen.go", "src/app/lib/lib.go", "src/app/main.go"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	// правила директорий между корнем поиска и проектом
+	got, err = findFiles(fsd, "src/app", []string{"*.go"}, IgnoreFor(fsd, "src", "src/app"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"src/app/lib/lib.go", "src/app/main.go"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion версия схемы JSON вывода. Увеличивается при несовместимом
+// изменении набора или смысла полей.
+const SchemaVersion = 1
+
+// todoJSON представление Todos в JSON
+type todoJSON struct {
+	Version  int        `json:"version,omitempty"`  // версия схемы, только для JSON Lines
+	Project  string     `json:"project"`            // путь к проекту
+	File     string     `json:"file"`               // путь к файлу
+	Line     int        `json:"line"`               // номер строки начала блока
+	Column   int        `json:"column,omitempty"`   // номер колонки начала комментария
+	Tag      string     `json:"tag"`                // тег блока
+	Lines    []string   `json:"lines"`              // строки комментариев блока
+	Owners   []string   `json:"owners,omitempty"`   // ответственные
+	Issues   []string   `json:"issues,omitempty"`   // ссылки на задачи
+	Priority int        `json:"priority,omitempty"` // приоритет от 1 до 9
+	Due      string     `json:"due,omitempty"`      // срок в формате YYYY-MM-DD
+	Blame    *blameJSON `json:"blame,omitempty"`    // коммит последним изменявший блок
+}
+
+// blameJSON представление Blame в JSON
+type blameJSON struct {
+	Commit string     `json:"commit"`           // хэш коммита или uncommitted
+	Author string     `json:"author,omitempty"` // имя автора
+	Email  string     `json:"email,omitempty"`  // почта автора
+	Time   *time.Time `json:"time,omitempty"`   // время коммита автора
+}
+
+// newTodoJSON возвращает представление блока в JSON
+func newTodoJSON(td Todos) todoJSON {
+	tj := todoJSON{Project: td.project, File: td.file, Line: td.line,
+		Column: td.col, Tag: td.tag, Lines: td.lines, Owners: td.meta.owners,
+		Issues: td.meta.issues, Priority: td.meta.priority}
+	if !td.meta.due.IsZero() {
+		tj.Due = td.meta.due.Format(DueLayout)
+	}
+	if td.blame.commit != "" {
+		tj.Blame = &blameJSON{Commit: td.blame.commit, Author: td.blame.author,
+			Email: td.blame.email}
+		if !td.blame.time.IsZero() {
+			tj.Blame.Time = &td.blame.time
+		}
+	}
+	return tj
+}
+
+// todos возвращает блок по его представлению в JSON
+func (tj todoJSON) todos() Todos {
+	td := Todos{lines: tj.Lines, tag: tj.Tag, file: tj.File, line: tj.Line,
+		col: tj.Column, project: tj.Project, position: tj.File + ":" + strconv.Itoa(tj.Line),
+		meta: Meta{owners: tj.Owners, issues: tj.Issues, priority: tj.Priority}}
+	if tj.Due != "" {
+		td.meta.due, _ = time.Parse(DueLayout, tj.Due)
+	}
+	if tj.Blame != nil {
+		td.blame = Blame{commit: tj.Blame.Commit, author: tj.Blame.Author,
+			email: tj.Blame.Email}
+		if tj.Blame.Time != nil {
+			td.blame.time = *tj.Blame.Time
+		}
+	}
+	return td
+}
+
+// MarshalJSON реализует интерфейс json.Marshaler
+func (td Todos) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newTodoJSON(td))
+}
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler
+func (td *Todos) UnmarshalJSON(data []byte) error {
+	var tj todoJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+	*td = tj.todos()
+	return nil
+}
+
+// errorJSON представление ошибки поиска в JSON
+type errorJSON struct {
+	Kind    string `json:"kind"`           // вид ошибки, например, permission
+	Path    string `json:"path,omitempty"` // путь к файлу или директории
+	Message string `json:"message"`        // текст ошибки
+}
+
+// newErrorJSON возвращает представление ошибки в JSON
+func newErrorJSON(err error) errorJSON {
+	ej := errorJSON{Kind: ErrorKind(err), Message: err.Error()}
+	var se *ScanError
+	if errors.As(err, &se) {
+		ej.Path = se.Path
+	}
+	return ej
+}
+
+// reportJSON документ JSON вывода
+type reportJSON struct {
+	Version int         `json:"version"`          // версия схемы
+	Todos   []Todos     `json:"todos"`            // найденные блоки
+	Errors  []errorJSON `json:"errors,omitempty"` // ошибки поиска
+}
+
+// WriteJSON выводит список блоков как один JSON документ с указанием версии
+// схемы.
+func WriteJSON(w io.Writer, todos []Todos) error {
+	return WriteJSONErrors(w, todos, nil)
+}
+
+// WriteJSONErrors выводит список блоков и ошибки поиска как один JSON
+// документ.
+func WriteJSONErrors(w io.Writer, todos []Todos, errs []error) error {
+	report := reportJSON{Version: SchemaVersion, Todos: todos}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, newErrorJSON(err))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteJSONL выводит каждый блок отдельной строкой в формате JSON Lines. Каждый
+// объект содержит версию схемы.
+func WriteJSONL(w io.Writer, todos []Todos) error {
+	return WriteJSONLErrors(w, todos, nil)
+}
+
+// WriteJSONLErrors выводит блоки и после них ошибки поиска в формате JSON
+// Lines. Ошибка выводится объектом с версией схемы и полем error.
+func WriteJSONLErrors(w io.Writer, todos []Todos, errs []error) error {
+	enc := json.NewEncoder(w)
+	for _, td := range todos {
+		tj := newTodoJSON(td)
+		tj.Version = SchemaVersion
+		if err := enc.Encode(tj); err != nil {
+			return err
+		}
+	}
+	for _, e := range errs {
+		line := struct {
+			Version int       `json:"version"`
+			Error   errorJSON `json:"error"`
+		}{SchemaVersion, newErrorJSON(e)}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test_JSON тестирует вывод в формате JSON и JSON Lines. Документ JSON должен
+// содержать версию схемы и читаться обратно в тот же список блоков, каждая
+// строка JSON Lines должна быть отдельным объектом с версией схемы.
+func Test_JSON(t *testing.T) {
+	header := "json:"
+	data := []Todos{
+		NewTodos("TODO", " in hello", "/src/hello/main.go", 2),
+		NewTodos("FIXME", " in world", "/src/world/main.go", 1)}
+	data[0].project = "/src/hello"
+	data[1].project = "/src/world"
+	data[1].meta, _ = parseMeta("alice, #7, p2, due:2026-12-01")
+
+	var b strings.Builder
+	if err := WriteJSON(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	var report reportJSON
+	if err := json.Unmarshal([]byte(b.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != SchemaVersion {
+		t.Errorf("%s версия схемы: требуется: %d, имеется: %d", header,
+			SchemaVersion, report.Version)
+	}
+	if guardLenght(t, header, len(data), len(report.Todos)) {
+		t.Fatal(report.Todos)
+	}
+	for i := range data {
+		if data[i].String() != report.Todos[i].String() {
+			t.Errorf("%s не равны: требуется: %v, имеется: %v", header,
+				data[i], report.Todos[i])
+		}
+	}
+
+	b.Reset()
+	if err := WriteJSONL(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(b.String()), "\n")
+	want := []string{
+		`{"version":1,"project":"/src/hello","file":"/src/hello/main.go","line":2,"tag":"TODO","lines":[" in hello"]}`,
+		`{"version":1,"project":"/src/world","file":"/src/world/main.go","line":1,"tag":"FIXME","lines":[" in world"],"owners":["alice"],"issues":["#7"],"priority":2,"due":"2026-12-01"}`}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
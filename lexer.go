@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import "strings"
+
+// состояния лексического анализатора
+const (
+	stateCode    = iota // код вне строк и комментариев
+	stateString         // строка с экранированием
+	stateRaw            // строка без экранирования
+	stateComment        // много строчный комментарий
+	stateMulti          // много строчная строка с экранированием
+)
+
+// lexer конечный автомат для поиска комментариев в строках файла. Состояние
+// много строчного комментария, много строчной строки и строки без
+// экранирования сохраняется между строками файла.
+type lexer struct {
+	cs    CommentSimbols
+	state int
+	quote byte   // символ открывший строку
+	multi string // символы открывшие много строчную строку
+}
+
+// newLexer возвращает анализатор для указанных символов комментариев
+func newLexer(cs CommentSimbols) *lexer {
+	return &lexer{cs: cs, state: stateCode}
+}
+
+// scanLine разбирает очередную строку файла. Возвращает true если в строке
+// найден комментарий, номер колонки начала первого комментария и содержание
+// всех комментариев строки без символов комментария.
+func (lx *lexer) scanLine(str string) (bool, int, string) {
+	found := false
+	col := 0
+	if lx.state == stateComment {
+		col = 1
+	}
+	var comment strings.Builder
+	for i := 0; i < len(str); {
+		switch lx.state {
+		case stateComment:
+			found = true
+			idx := strings.Index(str[i:], lx.cs.multiLineClose)
+			if idx < 0 {
+				comment.WriteString(str[i:])
+				i = len(str)
+				continue
+			}
+			comment.WriteString(str[i : i+idx])
+			i += idx + len(lx.cs.multiLineClose)
+			lx.state = stateCode
+		case stateString:
+			if str[i] == '\\' {
+				i += 2
+				continue
+			}
+			if str[i] == lx.quote {
+				lx.state = stateCode
+			}
+			i++
+		case stateRaw:
+			if str[i] == lx.quote {
+				lx.state = stateCode
+			}
+			i++
+		case stateMulti:
+			if str[i] == '\\' {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(str[i:], lx.multi) {
+				lx.state = stateCode
+				i += len(lx.multi)
+				continue
+			}
+			i++
+		default:
+			rest := str[i:]
+			if multi := lx.multiQuote(rest); multi != "" {
+				lx.state = stateMulti
+				lx.multi = multi
+				i += len(multi)
+				continue
+			}
+			switch {
+			case hasToken(rest, lx.cs.multiLineOpen):
+				lx.state = stateComment
+				found = true
+				if col == 0 {
+					col = i + 1
+				}
+				i += len(lx.cs.multiLineOpen)
+			case hasToken(rest, lx.cs.oneLine):
+				comment.WriteString(rest[len(lx.cs.oneLine):])
+				if col == 0 {
+					col = i + 1
+				}
+				return true, col, comment.String()
+			case strings.IndexByte(lx.cs.quotes, str[i]) > -1:
+				lx.state = stateString
+				lx.quote = str[i]
+				i++
+			case strings.IndexByte(lx.cs.rawQuotes, str[i]) > -1:
+				lx.state = stateRaw
+				lx.quote = str[i]
+				i++
+			default:
+				i++
+			}
+		}
+	}
+	// строка с экранированием не может продолжаться на следующей строке
+	if lx.state == stateString {
+		lx.state = stateCode
+	}
+	// пустая строка внутри много строчного комментария
+	if lx.state == stateComment {
+		found = true
+	}
+	return found, col, comment.String()
+}
+
+// multiQuote возвращает символы много строчной строки, с которых начинается
+// str, или пустую строку
+func (lx *lexer) multiQuote(str string) string {
+	for _, multi := range strings.Fields(lx.cs.multiQuotes) {
+		if strings.HasPrefix(str, multi) {
+			return multi
+		}
+	}
+	return ""
+}
+
+// hasToken проверяет что строка начинается с не пустого символа комментария
+func hasToken(str string, tok string) bool {
+	return len(tok) > 0 && strings.HasPrefix(str, tok)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import "testing"
+
+// Test_Lexer тестирует разбор строк файла конечным автоматом. Символы
+// комментариев внутри строк с экранированием и без него должны игнорироваться,
+// несколько комментариев в одной строке объединяться, а много строчный
+// комментарий продолжаться на следующих строках.
+func Test_Lexer(t *testing.T) {
+	type result struct {
+		found   bool
+		col     int
+		comment string
+	}
+	data := []struct {
+		line string
+		want result
+	}{
+		{`a := "escaped \" // quote"`, result{false, 0, ""}},
+		{`c := '"' // rune`, result{true, 10, " rune"}},
+		{`/* a */ x // b`, result{true, 1, " a  b"}},
+		{"s := `raw \\` /* open", result{true, 14, " open"}},
+		{"", result{true, 1, ""}},
+		{"close */ d := `raw", result{true, 1, "close "}},
+		{"// still raw */", result{false, 0, ""}},
+		{"` // end", result{true, 3, " end"}},
+	}
+
+	lex := newLexer(CommentSimbols{"//", "/*", "*/", `"'`, "`", ""})
+	for i := range data {
+		found, col, comment := lex.scanLine(data[i].line)
+		if (result{found, col, comment}) != data[i].want {
+			t.Errorf("строка %d: требуется: %v, имеется: %v", i+1,
+				data[i].want, result{found, col, comment})
+		}
+	}
+}
+
+// Test_LexerMultiString тестирует много строчные строки: шаблонные строки
+// JavaScript и строки в тройных кавычках Python. Символы комментариев внутри
+// таких строк должны игнорироваться на всех строках, а экранированные
+// кавычки не должны закрывать строку.
+func Test_LexerMultiString(t *testing.T) {
+	type result struct {
+		found   bool
+		col     int
+		comment string
+	}
+	data := []struct {
+		syntax string
+		line   string
+		want   result
+	}{
+		{"a.js", "const s = `first // not", result{false, 0, ""}},
+		{"a.js", "# \\` still // not", result{false, 0, ""}},
+		{"a.js", "end` // TODO: after", result{true, 6, " TODO: after"}},
+		{"a.py", `def f():`, result{false, 0, ""}},
+		{"a.py", `    """Docstring # not`, result{false, 0, ""}},
+		{"a.py", `    # TODO: not a comment`, result{false, 0, ""}},
+		{"a.py", `    ''' \""" # still not`, result{false, 0, ""}},
+		{"a.py", `    """ # TODO: comment`, result{true, 9, " TODO: comment"}},
+		{"a.py", `x = '''one''' # real`, result{true, 15, " real"}},
+	}
+
+	lexers := map[string]*lexer{}
+	for i := range data {
+		lex, ok := lexers[data[i].syntax]
+		if !ok {
+			cs, _ := FindSyntax(data[i].syntax)
+			lex = newLexer(cs)
+			lexers[data[i].syntax] = lex
+		}
+		found, col, comment := lex.scanLine(data[i].line)
+		if (result{found, col, comment}) != data[i].want {
+			t.Errorf("строка %d: требуется: %v, имеется: %v", i+1,
+				data[i].want, result{found, col, comment})
+		}
+	}
+}
@@ -22,14 +22,16 @@
 // последовательность символов «TODO:» составляется ссылка формата: [file
 // path]:[line number], где line number >= 1.
 //
-// Программа начинает поиск проектов в текущей рабочей директории если не указан
-// путь к папке с проектами как аргумент при вызове: todolist [directory path]
+// Программа начинает поиск проектов в текущей рабочей директории если не указаны
+// пути к папкам с проектами как аргументы при вызове:
+// todolist [flags] [directory path ...]
 //
 package main
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -59,147 +61,208 @@ func isMatchAny(patterns []string, str string) (bool, error) {
 // находиться в корне папки. Маркеры задаются как файловый шаблон командной
 // оболочки. При поиске директорий по указанному пути игнорируются директории
 // название которых начинается с символа «.», такие директории считаются
-// скрытыми.
+// скрытыми, а так же директории исключённые файлами .gitignore и .todoignore.
+//
+// Модули рабочего пространства перечисленные директивами use файла go.work в
+// директории проекта так же считаются проектами.
 //
 // Возвращает ошибки файловой системы, а так же ошибки синтаксиса описания
 // файловых шаблонов командной оболочки системы на которой происходит выполнение.
+// Ошибки чтения директорий не прерывают поиск, все они возвращаются списком
+// Errors вместе с найденными проектами.
 func FindProjects(fsd fs.FS, path string, markers []string) ([]string, error) {
+	prjlist, errs := findProjects(fsd, path, markers, (*Ignore)(nil).Load(fsd, path), false)
+	return uniqueStrings(prjlist), errs.errorOrNil()
+}
+
+// FindNestedProjects возвращает список директорий проектов как и
+// FindProjects, но продолжает поиск внутри найденных проектов, поэтому
+// находит вложенные проекты, например, модули монорепозитория. Проект
+// всегда предшествует вложенным в него проектам.
+func FindNestedProjects(fsd fs.FS, path string, markers []string) ([]string, error) {
+	prjlist, errs := findProjects(fsd, path, markers, (*Ignore)(nil).Load(fsd, path), true)
+	return uniqueStrings(prjlist), errs.errorOrNil()
+}
+
+// findProjects ищет проекты в директории path с учётом правил исключения ig
+// действующих в этой директории. Если nested равно false, поиск внутри
+// найденного проекта не продолжается.
+func findProjects(fsd fs.FS, path string, markers []string, ig *Ignore, nested bool) ([]string, Errors) {
 	dir, err := fs.ReadDir(fsd, path)
 	if err != nil {
-		return []string{}, err
+		return []string{}, Errors{NewScanError(path, err)}
 	}
 
 	prjlist := make([]string, 0)
+	errs := Errors{}
 	for _, elm := range dir {
 		found, err := isMatchAny(markers, elm.Name())
 		if err != nil {
-			return prjlist, err
+			return prjlist, Errors{NewScanError(path, err)}
 		}
 		if found {
 			prjlist = append(prjlist, path)
-			return prjlist, nil
+			prjlist = append(prjlist, goWorkModules(fsd, path)...)
+			break
 		}
-		// сканируем вложенную директорию если она не скрытая
-		if elm.IsDir() && !strings.HasPrefix(elm.Name(), ".") {
-			sublist, err := FindProjects(fsd, filepath.Join(path, elm.Name()), markers)
-			if err != nil {
-				return prjlist, nil
-			}
+	}
+	if len(prjlist) > 0 && !nested {
+		return prjlist, errs
+	}
+
+	for _, elm := range dir {
+		// сканируем вложенную директорию если она не скрытая и не исключена
+		sub := filepath.Join(path, elm.Name())
+		if elm.IsDir() && !strings.HasPrefix(elm.Name(), ".") && !ig.Match(sub, true) {
+			sublist, suberrs := findProjects(fsd, sub, markers, ig.Load(fsd, sub), nested)
 			prjlist = append(prjlist, sublist...)
+			errs = append(errs, suberrs...)
+			// ошибка в шаблоне маркера повторится в каждой директории
+			if errors.Is(suberrs, ErrBadPattern) {
+				return prjlist, errs
+			}
 		}
 	}
 
-	return prjlist, nil
+	return prjlist, errs
+}
+
+// uniqueStrings возвращает список без повторов, сохраняя порядок первого
+// появления
+func uniqueStrings(list []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(list))
+	for _, str := range list {
+		if !seen[str] {
+			seen[str] = true
+			result = append(result, str)
+		}
+	}
+	return result
 }
 
 // FindFiles функции передаются директория и список расширений в формате
 // файловых шаблонов. Возвращает список файлов в данной и вложенных в неё
 // директорий удовлетворяющих шаблону как массив строк или код ошибки файловой
-// системы.
+// системы. Недоступные файлы и директории пропускаются, ошибки доступа к ним
+// возвращаются списком Errors. Файлы и директории исключённые файлами
+// .gitignore и .todoignore пропускаются, правила применяются по мере обхода
+// директорий так же как это делает git.
 func FindFiles(fsd fs.FS, path string, ext []string) ([]string, error) {
+	return findFiles(fsd, path, ext, nil, nil)
+}
+
+// findFiles ищет файлы в директории path с учётом правил исключения ig
+// действующих в родительской директории path. Директории из skip, например,
+// вложенные проекты, пропускаются.
+func findFiles(fsd fs.FS, path string, ext []string, ig *Ignore, skip map[string]bool) ([]string, error) {
 	result := make([]string, 0)
+	errs := Errors{}
+	ignores := map[string]*Ignore{filepath.Dir(path): ig}
 	err := fs.WalkDir(fsd, path,
 		func(p string, d fs.DirEntry, e error) error {
 			if e != nil {
-				return e
+				errs = append(errs, NewScanError(p, e))
+				if d != nil && d.IsDir() && p != path {
+					return fs.SkipDir
+				}
+				return nil
 			}
-			// пропускаем скрытые директории
-			if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
-				return fs.SkipDir
+			parent := ignores[filepath.Dir(p)]
+			if d.IsDir() {
+				// пропускаем скрытые и исключённые директории
+				if p != path && (strings.HasPrefix(d.Name(), ".") || parent.Match(p, true) || skip[p]) {
+					return fs.SkipDir
+				}
+				ignores[p] = parent.Load(fsd, p)
+				return nil
 			}
 			found, err := isMatchAny(ext, d.Name())
 			if err != nil {
-				return err
+				return NewScanError(p, err)
 			}
-			if !d.IsDir() && found {
+			if found && !parent.Match(p, false) {
 				result = append(result, p)
 			}
 			return nil
 		})
 	if err != nil {
-		return result, err
+		errs = append(errs, err)
 	}
-	return result, nil
+	return result, errs.errorOrNil()
 }
 
 // CommentSimbols определяет символы комментариев для формата файла. Определяет
 // символ для одно строчного комментария и открывающий и закрывающий символ для
-// много строчного комментария.
+// много строчного комментария, а так же символы строковых литералов, внутри
+// которых символы комментариев не учитываются.
 type CommentSimbols struct {
 	oneLine        string // символ для одно строчного комментария
 	multiLineOpen  string // символ для начала много строчного комментария
 	multiLineClose string // символ для конца много строчного комментария
+	quotes         string // символы строк с экранированием через «\»
+	rawQuotes      string // символы строк без экранирования
+	multiQuotes    string // символы много строчных строк с экранированием через пробел
 }
 
 // CommentLine сопоставляет номер строки в файле, содержанию комментария
 type CommentLine struct {
 	line int    // номер строки
+	col  int    // номер колонки начала комментария, начиная с 1
 	data string // содержание строки комментария
 }
 
 // FindComments функции предаётся строка с путём к файлу и интерфейс для
 // определения строки комментария, возвращается список комментариев с указанием
-// номера строки от начала файла или ошибку файловой системы.
+// номера строки от начала файла или ошибку файловой системы ScanError.
+//
+// Несколько комментариев в одной строке объединяются в одну строку
+// комментария. Символы комментариев внутри строковых литералов игнорируются.
+// Комментарии Go файлов находятся с помощью go/scanner, если символы
+// комментариев не переопределены в настройках.
 func FindComments(fsd fs.FS, file string, cs CommentSimbols) ([]CommentLine, error) {
+	if filepath.Ext(file) == ".go" && cs == goSimbols {
+		return findGoComments(fsd, file)
+	}
 	result := make([]CommentLine, 0)
 	reader, err := fsd.Open(file)
 	if err != nil {
-		return result, nil
+		return result, NewScanError(file, err)
 	}
+	defer reader.Close()
 	scanner := bufio.NewScanner(reader)
-	mlc := false
+	lex := newLexer(cs)
 	line := 1
 	for scanner.Scan() {
-		comment := ""
-		if ok, str := getStringAfter(scanner.Text(), cs.multiLineOpen); ok {
-			comment = str
-			mlc = true
-		}
-		if ok, str := getStringBefore(scanner.Text(), cs.multiLineClose); ok {
-			comment = str
-			mlc = false
-		}
-		if ok, str := getStringAfter(scanner.Text(), cs.oneLine); ok {
-			comment = str
-		}
-		if mlc {
-			comment = scanner.Text()
-		}
-		if len(comment) > 0 {
-			result = append(result, CommentLine{line, comment})
+		if ok, col, comment := lex.scanLine(scanner.Text()); ok {
+			result = append(result, CommentLine{line, col, comment})
 		}
 		line++
 	}
-	return result, nil
+	return result, NewScanError(file, scanner.Err())
 }
 
-// getStringAfter возвращает все что после найденного паттерна
-func getStringAfter(str string, tok string) (bool, string) {
-	idx := strings.Index(str, tok)
-	if idx > -1 {
-		return true, str[idx+len(tok):]
-	}
-	return false, str
-}
-
-// getStringBefore возвращает все что перед найденным паттерном
-func getStringBefore(str string, tok string) (bool, string) {
-	idx := strings.Index(str, tok)
-	if idx > -1 {
-		return true, str[:idx-len(tok)+1]
-	}
-	return false, str
-}
+// DefaultTags список тегов по умолчанию, которыми отмечаются комментарии
+var DefaultTags = []string{"TODO", "FIXME", "HACK", "XXX", "BUG", "NOTE"}
 
 // Todos определяет блок комментариев и его позиция в файле
 type Todos struct {
 	lines    []string // строки комментариев в блоке
 	position string   // ссылка на блок комментария в формате [file path]:[line]
+	tag      string   // тег которым отмечен блок, например, TODO или FIXME
+	file     string   // путь к файлу
+	line     int      // номер строки начала блока
+	col      int      // номер колонки начала комментария, начиная с 1
+	project  string   // путь к проекту которому принадлежит файл
+	root     string   // корень поиска, с которого начинаются пути file и project
+	blame    Blame    // коммит последним изменявший строку начала блока
+	meta     Meta     // ответственные, задачи, приоритет и срок из скобок после тега
 }
 
 // NewTodos возвращает пустой экземпляр структуры Todos
-func NewTodos(line string, pos string) Todos {
-	td := Todos{make([]string, 0), pos}
+func NewTodos(tag string, line string, file string, num int) Todos {
+	td := Todos{lines: make([]string, 0), tag: tag, file: file, line: num,
+		position: file + ":" + strconv.Itoa(num)}
 	td.AppendLine(line)
 	return td
 }
@@ -209,23 +272,74 @@ func (td *Todos) AppendLine(line string) {
 	td.lines = append(td.lines, line)
 }
 
-// String форматирует данные структуры в строку. Реализует интерфейс Stringer.
+// String форматирует данные структуры в строку как заголовок Org mode первого
+// уровня. Реализует интерфейс Stringer.
 func (td Todos) String() string {
-	return "* TODO " + strings.Join(td.lines, "\n") + "\n" + td.position
+	return td.org(1)
+}
+
+// tagBoundary символы комментариев, после которых может начинаться тег
+const tagBoundary = " \t/*#-;!<{["
+
+// findTag ищет в строке первый по положению тег из списка, за которым следует
+// символ «:» или сведения в скобках и символ «:», например, TODO(alice): .
+// Сведения заканчиваются первой закрывающей скобкой, за которой сразу должен
+// следовать символ «:».
+// Тег должен начинаться в начале строки или после пробела или символа
+// комментария, поэтому BUG не находится в DEBUG: . Возвращает тег,
+// содержание скобок и индекс начала текста после «:», если тег не найден
+// индекс равен -1.
+func findTag(str string, tags []string) (string, string, int) {
+	tag, meta, first, next := "", "", -1, -1
+	for _, t := range tags {
+		for from := 0; from < len(str); {
+			idx := strings.Index(str[from:], t)
+			if idx < 0 {
+				break
+			}
+			idx += from
+			from = idx + len(t)
+			if first > -1 && idx >= first {
+				break
+			}
+			if idx > 0 && strings.IndexByte(tagBoundary, str[idx-1]) < 0 {
+				continue
+			}
+			rest := str[idx+len(t):]
+			if strings.HasPrefix(rest, ":") {
+				tag, meta, first, next = t, "", idx, idx+len(t)+1
+				break
+			}
+			if end := strings.IndexByte(rest, ')'); strings.HasPrefix(rest, "(") && end > 0 &&
+				strings.HasPrefix(rest[end+1:], ":") {
+				tag, meta, first, next = t, rest[1:end], idx, idx+len(t)+end+2
+				break
+			}
+		}
+	}
+	return tag, meta, next // игнорируем сам тег и сведения
 }
 
 // FindTodos функции передаются: путь к файлу, список комментариев CommentLine,
-// строку содержащею путь к файлу и строка паттерна, возвращает список структур
-// вида [список строк комментариев][ссылка в описанном формате]
-func FindTodos(path string, comments []CommentLine, token string) []Todos {
+// строку содержащею путь к файлу и список тегов, возвращает список структур
+// вида [список строк комментариев][ссылка в описанном формате][тег]. Сведения
+// в скобках после тега, например, TODO(alice, #123, p1, due:2026-12-01): ,
+// сохраняются отдельно от текста блока. Ошибки разбора сведений возвращаются
+// списком Errors, блоки с такими сведениями всё равно возвращаются.
+func FindTodos(path string, comments []CommentLine, tags []string) ([]Todos, error) {
 	result := make([]Todos, 0)
+	errs := Errors{}
 	todoOpen := false
 	nextLine := 0
 	for i := range comments {
-		if idx := strings.Index(comments[i].data, token); idx > -1 {
-			idx += len(token) // игнорируем сам токен
-			result = append(result, NewTodos(comments[i].data[idx:],
-				path+":"+strconv.Itoa(comments[i].line)))
+		if tag, meta, idx := findTag(comments[i].data, tags); idx > -1 {
+			td := NewTodos(tag, comments[i].data[idx:], path, comments[i].line)
+			td.col = comments[i].col
+			var err error
+			if td.meta, err = parseMeta(meta); err != nil {
+				errs = append(errs, &ScanError{ErrBadMeta, td.position, err})
+			}
+			result = append(result, td)
 			todoOpen = true
 			nextLine = comments[i].line + 1
 			continue
@@ -241,44 +355,24 @@ func FindTodos(path string, comments []CommentLine, token string) []Todos {
 			nextLine = comments[i].line + 1
 		}
 	}
-	return result
+	return result, errs.errorOrNil()
 }
 
-func main() {
-	baseDir := "/"
-	fsd := os.DirFS(baseDir)
-	dir := ""
-	if len(os.Args) > 1 {
-		dir = os.Args[1][1:]
-	}
-	prjlist, err := FindProjects(fsd, dir, []string{".git", "go.mod", "Makefile"})
-	if err != nil {
-		fmt.Println(err)
-	}
+// Writers форматы вывода найденных блоков по их названию
+var Writers = map[string]func(io.Writer, []Todos) error{
+	"org":      WriteOrg,
+	"json":     WriteJSON,
+	"jsonl":    WriteJSONL,
+	"sarif":    WriteSARIF,
+	"quickfix": WriteQuickfix,
+}
 
-	fileslist := map[string][]string{}
-	for i := range prjlist {
-		list, err := FindFiles(fsd, prjlist[i], []string{"*.go", "*.mod"})
-		if err != nil {
-			fmt.Println(err)
-		}
-		fileslist[prjlist[i]] = list
-	}
+// ErrorWriters форматы вывода, которые включают в отчёт ошибки поиска
+var ErrorWriters = map[string]func(io.Writer, []Todos, []error) error{
+	"json":  WriteJSONErrors,
+	"jsonl": WriteJSONLErrors,
+}
 
-	for _, prjfiles := range fileslist {
-		for filename := range prjfiles {
-			comments, err := FindComments(fsd, prjfiles[filename],
-				CommentSimbols{"//", "/*", "*/"})
-			if err != nil {
-				fmt.Println(err)
-			}
-			fmt.Println(prjfiles[filename])
-			fmt.Println("--------------------")
-			fmt.Println(comments)
-			todos := FindTodos(baseDir+prjfiles[filename], comments, "TODO:")
-			for i := range todos {
-				fmt.Println(todos[i])
-			}
-		}
-	}
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
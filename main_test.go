@@ -130,25 +130,29 @@ func Test_Comments(t *testing.T) {
 	header := "список комментариев:"
 
 	got, err := FindComments(os.DirFS("."), "testdata/hello/main_hello.go",
-		CommentSimbols{"//", "/*", "*/"})
+		CommentSimbols{"//", "/*", "*/", `"'`, "`", ""})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	want := []CommentLine{
-		{line: 2, data: " TODO: in hello"},
-		{line: 4, data: " Line two"},
-		{line: 6, data: " in func line"},
-		{line: 9, data: "/* Line three"},
-		{line: 10, data: "* Line four"},
-		{line: 13, data: "\ta := \"/* in string"}}
+		{line: 1, col: 1, data: ""},
+		{line: 2, col: 1, data: " TODO: in hello"},
+		{line: 3, col: 1, data: " Line two"},
+		{line: 4, col: 1, data: ""},
+		{line: 6, col: 9, data: " in func line"},
+		{line: 9, col: 1, data: ""},
+		{line: 10, col: 1, data: "* Line three"},
+		{line: 11, col: 1, data: "* Line four"},
+		{line: 12, col: 1, data: " "},
+		{line: 15, col: 13, data: " a  b"}}
 
 	if guardLenght(t, header, len(want), len(got)) {
 		t.Fatal(got)
 	}
 
 	for i := 0; i < len(got); i++ {
-		if got[i].line != want[i].line || got[i].data != want[i].data {
+		if got[i] != want[i] {
 			t.Errorf("%s не равны: требуется: %v, имеется: %v",
 				header, want[i], got[i])
 		}
@@ -177,9 +181,12 @@ func Test_FileTodolist(t *testing.T) {
 		{line: 6, data: " Line five"},
 		{line: 7, data: ""}}
 
-	got := FindTodos("testdata/hello/virtual.go", data, "TODO:")
-	want := []Todos{{[]string{" in hello", " Line two", " Line three"},
-		"testdata/hello/virtual.go:1"}}
+	got, err := FindTodos("testdata/hello/virtual.go", data, []string{"TODO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Todos{{lines: []string{" in hello", " Line two", " Line three"},
+		position: "testdata/hello/virtual.go:1", tag: "TODO"}}
 
 	if guardLenght(t, header, len(want), len(got)) {
 		t.Fatal(got)
@@ -189,27 +196,98 @@ func Test_FileTodolist(t *testing.T) {
 			t.Fatal(got[i].lines)
 		}
 		compareStrings(t, header, want[i].lines, got[i].lines)
-		if got[i].position != want[i].position {
+		if got[i].position != want[i].position || got[i].tag != want[i].tag {
 			t.Errorf("%s не равны: требуется: %v, имеется: %v",
 				header, want[i], got[i])
 		}
 	}
 }
 
+// Test_Tags тестируем поиск нескольких тегов за один проход. Для каждого
+// найденного блока должен сохраняться тег которым он отмечен, при нескольких
+// тегах в одной строке учитываться первый из них, а тег внутри слова, например,
+// BUG в DEBUG, не должен находиться.
+func Test_Tags(t *testing.T) {
+	header := "список тегов:"
+	data := []CommentLine{
+		{line: 1, data: " FIXME: broken"},
+		{line: 2, data: " TODO: later"},
+		{line: 4, data: " XXX: first HACK: second"},
+		{line: 6, data: " NOTE: not in list"}}
+
+	todos, err := FindTodos("virtual.go", data, []string{"TODO", "FIXME", "HACK", "XXX"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].tag+todos[i].lines[0])
+	}
+	want := []string{"FIXME broken", "TODO later", "XXX first HACK: second"}
+
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	data = []CommentLine{
+		{line: 1, data: " DEBUG: print state"},
+		{line: 3, data: " see FOOTNOTE: x"},
+		{line: 5, data: " NOTEBOOK: y"},
+		{line: 7, data: "*BUG: after star"}}
+	if todos, err = FindTodos("virtual.go", data, []string{"BUG", "NOTE"}); err != nil {
+		t.Fatal(err)
+	}
+	got = make([]string, 0)
+	for i := range todos {
+		got = append(got, todos[i].tag+todos[i].lines[0])
+	}
+	want = []string{"BUG after star"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
+
 // Test_Format тестируем функцию форматирования Todos. Функция возвращает
-// содержимое структуры как строку вида: «* TODO [строки данных][позиция]».
+// содержимое структуры как заголовок Org mode вида: «* TODO [первая строка]»,
+// блок свойств, остальные строки данных и ссылку Org mode на строку файла.
 //
 // Тестируем сравнивая с тестовой строкой.
 func Test_Format(t *testing.T) {
-	data := Todos{lines: []string{"line first", "line second"},
-		position: "testdata/hello/virtual.go:1"}
+	data := NewTodos("TODO", "line first", "/src/hello/virtual.go", 1)
+	data.AppendLine("line second")
+	data.project = "/src/hello"
 	want := `* TODO line first
+:PROPERTIES:
+:FILE: /src/hello/virtual.go
+:LINE: 1
+:PROJECT: /src/hello
+:TAG: TODO
+:END:
 line second
-testdata/hello/virtual.go:1`
+[[file:/src/hello/virtual.go::1][/src/hello/virtual.go:1]]`
 	got := data.String()
 
 	if want != got {
 		t.Errorf("форматирование: строки не равны: требуется %s, имеется %s",
 			want, got)
 	}
+
+	data.tag = "FIXME"
+	want = `* TODO line first :FIXME:
+:PROPERTIES:
+:FILE: /src/hello/virtual.go
+:LINE: 1
+:PROJECT: /src/hello
+:TAG: FIXME
+:END:
+line second
+[[file:/src/hello/virtual.go::1][/src/hello/virtual.go:1]]`
+	got = data.String()
+
+	if want != got {
+		t.Errorf("форматирование: строки не равны: требуется %s, имеется %s",
+			want, got)
+	}
 }
@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memNode файл или директория виртуальной файловой системы memFS
+type memNode struct {
+	name     string                 // имя файла без пути
+	mode     fs.FileMode            // тип и права доступа
+	size     int64                  // размер файла
+	modTime  time.Time              // время изменения
+	load     func() ([]byte, error) // читает содержимое файла
+	children map[string]*memNode    // содержимое директории
+}
+
+func (n *memNode) Name() string               { return n.name }
+func (n *memNode) Size() int64                { return n.size }
+func (n *memNode) Mode() fs.FileMode          { return n.mode }
+func (n *memNode) ModTime() time.Time         { return n.modTime }
+func (n *memNode) IsDir() bool                { return n.mode.IsDir() }
+func (n *memNode) Sys() interface{}           { return nil }
+func (n *memNode) Type() fs.FileMode          { return n.mode.Type() }
+func (n *memNode) Info() (fs.FileInfo, error) { return n, nil }
+
+// memFS файловая система, дерево которой хранится в памяти, а содержимое
+// файлов читается функцией load при открытии файла. Используется для
+// ревизий git и архивов. Безопасна для одновременного чтения после
+// заполнения.
+type memFS struct {
+	root *memNode
+}
+
+// newMemFS возвращает пустую файловую систему
+func newMemFS() *memFS {
+	return &memFS{root: &memNode{name: ".", mode: fs.ModeDir | 0o555,
+		children: map[string]*memNode{}}}
+}
+
+// mkdir возвращает директорию по пути name, создавая отсутствующие
+// директории. Если по пути находится файл, возвращает nil.
+func (m *memFS) mkdir(name string) *memNode {
+	dir := m.root
+	if name == "." || name == "" {
+		return dir
+	}
+	for _, elem := range strings.Split(name, "/") {
+		next, ok := dir.children[elem]
+		if !ok {
+			next = &memNode{name: elem, mode: fs.ModeDir | 0o555, modTime: dir.modTime,
+				children: map[string]*memNode{}}
+			dir.children[elem] = next
+		}
+		if !next.IsDir() {
+			return nil
+		}
+		dir = next
+	}
+	return dir
+}
+
+// addFile добавляет файл по пути name. Содержимое файла читается функцией load
+// при каждом открытии. Файлы с неверным путём или путём через файл
+// пропускаются.
+func (m *memFS) addFile(name string, size int64, modTime time.Time, load func() ([]byte, error)) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) || name == "." {
+		return
+	}
+	dir := m.mkdir(path.Dir(name))
+	if dir == nil {
+		return
+	}
+	base := path.Base(name)
+	if old, ok := dir.children[base]; ok && old.IsDir() {
+		return
+	}
+	dir.children[base] = &memNode{name: base, mode: 0o444, size: size,
+		modTime: modTime, load: load}
+}
+
+// lookup возвращает файл или директорию по пути name
+func (m *memFS) lookup(op string, name string) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	node := m.root
+	if name == "." {
+		return node, nil
+	}
+	for _, elem := range strings.Split(name, "/") {
+		next, ok := node.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		node = next
+	}
+	return node, nil
+}
+
+// Open реализует интерфейс fs.FS
+func (m *memFS) Open(name string) (fs.File, error) {
+	node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.IsDir() {
+		return &memDir{node: node}, nil
+	}
+	data, err := node.load()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &memFile{node: node, Reader: bytes.NewReader(data)}, nil
+}
+
+// Stat реализует интерфейс fs.StatFS
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// memFile открытый файл memFS
+type memFile struct {
+	node *memNode
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir открытая директория memFS
+type memDir struct {
+	node    *memNode
+	once    sync.Once
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.node, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir реализует интерфейс fs.ReadDirFile, элементы упорядочены по имени
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	d.once.Do(func() {
+		for _, child := range d.node.children {
+			d.entries = append(d.entries, child)
+		}
+		sort.Slice(d.entries, func(i, j int) bool {
+			return d.entries[i].Name() < d.entries[j].Name()
+		})
+	})
+	rest := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+	return rest[:count], nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DueLayout формат срока в сведениях блока, например, due:2026-12-01
+const DueLayout = "2006-01-02"
+
+// Meta сведения о блоке из скобок после тега, например,
+// TODO(alice, #123, p1, due:2026-12-01): text
+type Meta struct {
+	owners   []string  // ответственные
+	issues   []string  // ссылки на задачи, например, #123 или PROJ-42
+	priority int       // приоритет от 1 до 9 из записи p1...p9, 0 если не указан
+	due      time.Time // срок, нулевое время если не указан
+}
+
+// issueKey шаблон ключа задачи трекера вида PROJ-42
+var issueKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*-[0-9]+$`)
+
+// parseMeta разбирает сведения из скобок после тега. Элементы разделяются
+// запятыми: #123, ключ задачи вида PROJ-42 или ссылка http(s) считаются
+// задачей, p1...p9 приоритетом, due:YYYY-MM-DD сроком, остальные элементы
+// считаются ответственными, символ «@» в начале имени отбрасывается. Срок в
+// неверном формате не сохраняется и возвращается ошибкой, остальные сведения
+// разбираются.
+func parseMeta(str string) (Meta, error) {
+	var m Meta
+	var err error
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+		case strings.HasPrefix(item, "#") || issueKey.MatchString(item) ||
+			strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://"):
+			m.issues = append(m.issues, item)
+		case len(item) == 2 && (item[0] == 'p' || item[0] == 'P') &&
+			item[1] >= '1' && item[1] <= '9':
+			m.priority = int(item[1] - '0')
+		case strings.HasPrefix(item, "due:"):
+			due, e := time.Parse(DueLayout, strings.TrimSpace(item[len("due:"):]))
+			if e != nil {
+				err = fmt.Errorf("срок %q: %w", item, e)
+				continue
+			}
+			m.due = due
+		default:
+			m.owners = append(m.owners, strings.TrimPrefix(item, "@"))
+		}
+	}
+	return m, err
+}
+
+// IsZero возвращает true если сведения не указаны
+func (m Meta) IsZero() bool {
+	return len(m.owners) == 0 && len(m.issues) == 0 && m.priority == 0 && m.due.IsZero()
+}
+
+// String форматирует сведения так же как они записываются в скобках после
+// тега. Реализует интерфейс Stringer.
+func (m Meta) String() string {
+	items := append(append([]string{}, m.owners...), m.issues...)
+	if m.priority > 0 {
+		items = append(items, "p"+strconv.Itoa(m.priority))
+	}
+	if !m.due.IsZero() {
+		items = append(items, "due:"+m.due.Format(DueLayout))
+	}
+	return strings.Join(items, ", ")
+}
+
+// orgPriority возвращает приоритет Org mode, p1 соответствует [#A], p2 [#B],
+// p3 и более низкие приоритеты [#C], так как Org mode по умолчанию знает
+// только приоритеты A, B и C. Если приоритет не указан, возвращается пустая
+// строка.
+func (m Meta) orgPriority() string {
+	if m.priority == 0 {
+		return ""
+	}
+	priority := m.priority
+	if priority > 3 {
+		priority = 3
+	}
+	return "[#" + string(rune('A'+priority-1)) + "]"
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test_Meta тестирует разбор сведений в скобках после тега. Текст блока должен
+// начинаться после «):», а сведения сохраняться отдельно и форматироваться
+// обратно в той же записи. Скобки без «:» сразу после первой закрывающей
+// скобки сведениями не считаются, а срок в неверном формате возвращается
+// ошибкой.
+func Test_Meta(t *testing.T) {
+	header := "сведения:"
+	comments := []CommentLine{
+		{1, 1, " TODO(alice, @bob, #123, PROJ-42, p1, due:2026-12-01): fix it"},
+		{2, 1, " and test"},
+		{5, 1, " FIXME: plain"},
+		{7, 1, " call(x): TODO(carol): later"},
+		{9, 1, " TODO(p2, due:someday): bad date"},
+		{11, 1, " TODO(later) then call(): x"}}
+	got, err := FindTodos("main.go", comments, DefaultTags)
+	if guardLenght(t, header, 4, len(got)) {
+		t.Fatal(got)
+	}
+	var se *ScanError
+	if !errors.Is(err, ErrBadMeta) || !errors.As(err, &se) || se.Path != "main.go:9" {
+		t.Errorf("%s требуется ошибка срока в main.go:9, имеется: %v", header, err)
+	}
+
+	want := []struct{ meta, lines string }{
+		{"alice, bob, #123, PROJ-42, p1, due:2026-12-01", " fix it\n and test"},
+		{"", " plain"},
+		{"carol", " later"},
+		{"p2", " bad date"}}
+	for i := range want {
+		if meta := got[i].meta.String(); meta != want[i].meta {
+			t.Errorf("%s %d: требуется: %q, имеется: %q", header, i, want[i].meta, meta)
+		}
+		if lines := strings.Join(got[i].lines, "\n"); lines != want[i].lines {
+			t.Errorf("%s %d: текст: требуется: %q, имеется: %q", header, i,
+				want[i].lines, lines)
+		}
+	}
+
+	for priority, want := range map[int]string{1: "[#A]", 2: "[#B]", 3: "[#C]", 9: "[#C]"} {
+		if p := (Meta{priority: priority}).orgPriority(); p != want {
+			t.Errorf("%s приоритет org p%d: требуется: %s, имеется: %s", header,
+				priority, want, p)
+		}
+	}
+	if !got[1].meta.IsZero() {
+		t.Errorf("%s не пусты для блока без скобок: %v", header, got[1].meta)
+	}
+
+	org := got[0].org(1)
+	for _, line := range []string{"* TODO [#A] fix it", "DEADLINE: <2026-12-01 Tue>",
+		":OWNER: alice, bob", ":ISSUE: #123, PROJ-42"} {
+		if !strings.Contains(org, line+"\n") {
+			t.Errorf("%s org: нет строки %q в\n%s", header, line, org)
+		}
+	}
+
+	qf := got[0].quickfix("")
+	if want := "main.go:1:1: TODO(alice, bob, #123, PROJ-42, p1, due:2026-12-01): fix it and test"; qf != want {
+		t.Errorf("%s quickfix: требуется: %s, имеется: %s", header, want, qf)
+	}
+}
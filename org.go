@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// orgLink возвращает ссылку Org mode на строку файла вида
+// [[file:/abs/path::LINE][path:LINE]]. Путь в ссылке всегда абсолютный, чтобы
+// Org mode мог перейти по ней из любого буфера.
+func orgLink(file string, line int, title string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	target := "file:" + abs
+	if line > 0 {
+		target += "::" + strconv.Itoa(line)
+	}
+	return "[[" + target + "][" + title + "]]"
+}
+
+// orgText экранирует строку текста блока, которая в Org mode стала бы
+// заголовком, строкой «*» в начале, или комментарием и ключевым словом, строкой
+// «#» после отступа. Перед такой строкой добавляется пробел нулевой ширины,
+// как рекомендует руководство Org mode.
+func orgText(line string) string {
+	if strings.HasPrefix(line, "*") || strings.HasPrefix(strings.TrimLeft(line, " \t"), "#") {
+		return "\u200b" + line
+	}
+	return line
+}
+
+// org форматирует блок как заголовок Org mode указанного уровня. Приоритет
+// блока выводится как приоритет заголовка, а срок строкой DEADLINE. За
+// заголовком следует блок свойств FILE, LINE, PROJECT, TAG, OWNER и ISSUE,
+// оставшиеся строки блока и ссылка на строку файла.
+func (td Todos) org(level int) string {
+	lines := append([]string{}, td.lines...)
+	if td.tag != "" && td.tag != "TODO" {
+		lines[0] += " :" + td.tag + ":"
+	}
+	if priority := td.meta.orgPriority(); priority != "" {
+		lines[0] = priority + " " + strings.TrimLeft(lines[0], " ")
+	}
+	var b strings.Builder
+	b.WriteString(strings.Repeat("*", level) + " TODO " + lines[0] + "\n")
+	if !td.meta.due.IsZero() {
+		b.WriteString("DEADLINE: " + td.meta.due.Format("<2006-01-02 Mon>") + "\n")
+	}
+	b.WriteString(":PROPERTIES:\n")
+	b.WriteString(":FILE: " + td.file + "\n")
+	b.WriteString(":LINE: " + strconv.Itoa(td.line) + "\n")
+	b.WriteString(":PROJECT: " + td.project + "\n")
+	b.WriteString(":TAG: " + td.tag + "\n")
+	if len(td.meta.owners) > 0 {
+		b.WriteString(":OWNER: " + strings.Join(td.meta.owners, ", ") + "\n")
+	}
+	if len(td.meta.issues) > 0 {
+		b.WriteString(":ISSUE: " + strings.Join(td.meta.issues, ", ") + "\n")
+	}
+	if td.blame.commit != "" {
+		b.WriteString(":COMMIT: " + td.blame.commit + "\n")
+	}
+	if td.blame.author != "" {
+		b.WriteString(":AUTHOR: " + td.blame.author + "\n")
+		b.WriteString(":EMAIL: " + td.blame.email + "\n")
+		b.WriteString(":DATE: " + td.blame.time.Format("[2006-01-02 Mon 15:04]") + "\n")
+	}
+	b.WriteString(":END:\n")
+	for _, line := range lines[1:] {
+		b.WriteString(orgText(line) + "\n")
+	}
+	b.WriteString(orgLink(td.file, td.line, td.position))
+	return b.String()
+}
+
+// WriteOrg выводит список блоков в формате Org mode как дерево заголовков:
+// заголовок первого уровня для каждого проекта, второго для каждого файла
+// проекта и блоки комментариев третьего уровня. Порядок проектов и файлов
+// соответствует порядку их первого появления в списке.
+func WriteOrg(w io.Writer, todos []Todos) error {
+	bw := bufio.NewWriter(w)
+	project, file := "", ""
+	for i, td := range todos {
+		if i == 0 || td.project != project {
+			project, file = td.project, ""
+			bw.WriteString("* " + orgLink(project, 0, project) + "\n")
+		}
+		if td.file != file {
+			file = td.file
+			title := file
+			if rel, err := filepath.Rel(project, file); err == nil && project != "" {
+				title = rel
+			}
+			bw.WriteString("** " + orgLink(file, 0, title) + "\n")
+		}
+		bw.WriteString(td.org(3) + "\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test_WriteOrg тестирует вывод дерева заголовков Org mode. Для каждого
+// проекта выводится заголовок первого уровня, для каждого файла второго, а
+// блоки комментариев выводятся заголовками третьего уровня.
+func Test_WriteOrg(t *testing.T) {
+	data := []Todos{
+		NewTodos("TODO", " in hello", "/src/hello/main.go", 2),
+		NewTodos("BUG", " in lib", "/src/hello/lib/lib.go", 7),
+		NewTodos("TODO", " in world", "/src/world/main.go", 1)}
+	data[0].project = "/src/hello"
+	data[1].project = "/src/hello"
+	data[2].project = "/src/world"
+
+	var b strings.Builder
+	if err := WriteOrg(&b, data); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0)
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "*") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"* [[file:/src/hello][/src/hello]]",
+		"** [[file:/src/hello/main.go][main.go]]",
+		"*** TODO  in hello",
+		"** [[file:/src/hello/lib/lib.go][lib/lib.go]]",
+		"*** TODO  in lib :BUG:",
+		"* [[file:/src/world][/src/world]]",
+		"** [[file:/src/world/main.go][main.go]]",
+		"*** TODO  in world"}
+
+	header := "заголовки org:"
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
+
+// Test_OrgText тестирует экранирование строк блока, которые Org mode
+// разобрал бы как заголовок, комментарий или ключевое слово. Такие строки
+// должны начинаться с пробела нулевой ширины, остальные выводиться как есть.
+func Test_OrgText(t *testing.T) {
+	td := NewTodos("TODO", " first", "/src/hello/main.go", 1)
+	for _, line := range []string{"* Line three", "#+TITLE: x", "  # comment", " * item", " text"} {
+		td.AppendLine(line)
+	}
+	lines := strings.Split(td.org(3), "\n")
+	got := make([]string, 0)
+	for i := range lines {
+		if lines[i] == ":END:" {
+			got = lines[i+1 : len(lines)-1]
+		}
+	}
+	want := []string{"\u200b* Line three", "\u200b#+TITLE: x", "\u200b  # comment", " * item", " text"}
+
+	header := "текст org:"
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
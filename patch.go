@@ -0,0 +1,262 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// patchHunk фрагмент изменений файла в формате unified diff
+type patchHunk struct {
+	oldStart int      // номер первой строки фрагмента в старом файле
+	newStart int      // номер первой строки фрагмента в новом файле
+	lines    []string // строки фрагмента с первым символом « », «+» или «-»
+}
+
+// patchFile изменения одного файла в формате unified diff. Для нового файла
+// oldName пустой, для удалённого пустой newName.
+type patchFile struct {
+	oldName string
+	newName string
+	hunks   []patchHunk
+}
+
+// patchName возвращает путь к файлу из строки заголовка «--- » или «+++ »:
+// без префиксов a/ и b/ git, времени изменения после табуляции и кавычек.
+// Для /dev/null возвращает пустую строку.
+func patchName(str string) string {
+	if tab := strings.IndexByte(str, '\t'); tab >= 0 {
+		str = str[:tab]
+	}
+	str = strings.TrimSpace(str)
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		str = unquoted
+	}
+	if str == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(str, "a/") || strings.HasPrefix(str, "b/") {
+		str = str[2:]
+	}
+	return str
+}
+
+// parseHunkRange разбирает диапазон заголовка фрагмента вида 12,5 или 12.
+// Если количество строк не указано, оно равно 1.
+func parseHunkRange(str string) (int, int, error) {
+	start, count := str, "1"
+	if comma := strings.IndexByte(str, ','); comma >= 0 {
+		start, count = str[:comma], str[comma+1:]
+	}
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	c, err := strconv.Atoi(count)
+	return s, c, err
+}
+
+// parsePatch разбирает изменения в формате unified diff, в том числе вывод
+// git diff. Строки вне фрагментов изменений, например, заголовки git и текст
+// письма, пропускаются. Границы фрагмента определяются по количеству строк в
+// его заголовке, поэтому удалённые строки вида «--- » не принимаются за
+// заголовок файла.
+func parsePatch(r io.Reader) ([]patchFile, error) {
+	files := make([]patchFile, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	oldLeft, newLeft := 0, 0
+	num := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		num++
+		if oldLeft > 0 || newLeft > 0 {
+			hunk := &files[len(files)-1].hunks[len(files[len(files)-1].hunks)-1]
+			switch {
+			case strings.HasPrefix(line, `\`):
+				continue // \ No newline at end of file
+			case line == "" || line[0] == ' ':
+				oldLeft--
+				newLeft--
+				line = " " + strings.TrimPrefix(line, " ")
+			case line[0] == '-':
+				oldLeft--
+			case line[0] == '+':
+				newLeft--
+			default:
+				return nil, fmt.Errorf("patch: строка %d: неверная строка фрагмента", num)
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("patch: строка %d: фрагмент длиннее заголовка", num)
+			}
+			hunk.lines = append(hunk.lines, line)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			files = append(files, patchFile{oldName: patchName(line[4:])})
+		case strings.HasPrefix(line, "+++ ") && len(files) > 0:
+			files[len(files)-1].newName = patchName(line[4:])
+		case strings.HasPrefix(line, "@@ ") && len(files) > 0:
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") ||
+				!strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("patch: строка %d: неверный заголовок фрагмента", num)
+			}
+			oldStart, oldCount, err1 := parseHunkRange(fields[1][1:])
+			newStart, newCount, err2 := parseHunkRange(fields[2][1:])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("patch: строка %d: неверный заголовок фрагмента", num)
+			}
+			file := &files[len(files)-1]
+			file.hunks = append(file.hunks, patchHunk{oldStart: oldStart, newStart: newStart})
+			oldLeft, newLeft = oldCount, newCount
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("patch: фрагмент короче заголовка")
+	}
+	return files, nil
+}
+
+// sideTodos находит блоки в строках одной стороны фрагментов: старой, sign
+// равен «-», или новой, sign равен «+». Строки разбираются с начала каждого
+// фрагмента символами комментариев cs с номерами строк соответствующего
+// файла. Возвращаются только блоки, которые содержат изменённые строки.
+func (pf patchFile) sideTodos(sign byte, file string, cs CommentSimbols, tags []string) []Todos {
+	result := make([]Todos, 0)
+	for _, hunk := range pf.hunks {
+		line := hunk.newStart
+		if sign == '-' {
+			line = hunk.oldStart
+		}
+		lex := newLexer(cs)
+		comments := make([]CommentLine, 0)
+		changed := map[int]bool{}
+		for _, str := range hunk.lines {
+			if str[0] != ' ' && str[0] != sign {
+				continue
+			}
+			if str[0] == sign {
+				changed[line] = true
+			}
+			if ok, col, comment := lex.scanLine(str[1:]); ok {
+				comments = append(comments, CommentLine{line, col, comment})
+			}
+			line++
+		}
+		// неверные сведения блока не мешают сравнению блоков
+		todos, _ := FindTodos(file, comments, tags)
+		for _, td := range todos {
+			for i := range td.lines {
+				if changed[td.line+i] {
+					result = append(result, td)
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// PatchTodos возвращает блоки, которые изменения в формате unified diff
+// добавляют, удаляют или изменяют. Формат файла определяется по имени файла
+// в списке syntaxes. Положение добавленных и изменённых блоков указывается в
+// новом файле, удалённых в старом. Изменения возвращаются по файлам в
+// порядке DiffTodos. Блоки ищутся только в строках фрагментов,
+// поэтому блок, который начинается за пределами фрагмента, не находится.
+func PatchTodos(r io.Reader, tags []string, syntaxes []Syntax) ([]TodoChange, error) {
+	files, err := parsePatch(r)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]TodoChange, 0)
+	for _, pf := range files {
+		name := pf.newName
+		if name == "" {
+			name = pf.oldName
+		}
+		cs, ok := findSyntax(syntaxes, name)
+		if !ok {
+			continue
+		}
+		before := pf.sideTodos('-', pf.oldName, cs, tags)
+		after := pf.sideTodos('+', pf.newName, cs, tags)
+		changes = append(changes, DiffTodos(before, after)...)
+	}
+	return changes, nil
+}
+
+// runPatch выполняет команду patch: читает изменения в формате unified diff
+// из файлов или стандартного ввода и выводит блоки, которые изменения
+// добавляют, удаляют или изменяют.
+func runPatch(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fset := flag.NewFlagSet("todolist patch", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), `Использование: todolist patch [флаги] [файл ...] < изменения.diff
+
+Читает изменения в формате unified diff из файлов или стандартного ввода и
+выводит добавленные «+», удалённые «-» и изменённые «~» блоки. Репозиторий
+не требуется, номера строк указываются в новом файле, для удалённых блоков в
+старом.
+
+Флаги:`)
+		fset.PrintDefaults()
+	}
+	var tags listFlag
+	fset.Var(&tags, "tag", "тег блока комментариев (можно повторять)")
+	format := fset.String("format", "text", "формат вывода: text или json")
+	if err := fset.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	write := WriteChanges
+	switch *format {
+	case "text":
+	case "json":
+		write = WriteChangesJSON
+	default:
+		fmt.Fprintln(stderr, "неизвестный формат вывода:", *format)
+		return exitUsage
+	}
+	if len(tags) == 0 {
+		tags = DefaultTags
+	}
+
+	inputs := []io.Reader{stdin}
+	if fset.NArg() > 0 {
+		inputs = inputs[:0]
+		for _, name := range fset.Args() {
+			file, err := os.Open(name)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+			defer file.Close()
+			inputs = append(inputs, file)
+		}
+	}
+	changes, err := PatchTodos(io.MultiReader(inputs...), tags, Syntaxes)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := write(stdout, changes); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
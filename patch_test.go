@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testPatch изменения трёх файлов: в lib.go продолжение блока FIXME в строке
+// контекста меняется, блок TODO удаляется и добавляется новый блок, а
+// удалённая строка SQL «--- » не должна приниматься за заголовок файла.
+// Файл new.py создаётся, формат notes.txt не известен.
+const testPatch = `diff --git a/lib/lib.go b/lib/lib.go
+index 1111111..2222222 100644
+--- a/lib/lib.go
++++ b/lib/lib.go
+@@ -10,7 +10,8 @@ func f() {
+ 	x := 1
+ 	// FIXME: fix bug
+-	// in parser
++	// in lexer
+ 	y := 2
+-	// TODO: gone
++	/* TODO(bob): new block
++	   spanning lines */
+ 	return
+ }
+--- a/db/schema.sql
++++ b/db/schema.sql
+@@ -1,2 +1,2 @@
+--- old comment
++-- NOTE: new comment
+ select 1;
+\ No newline at end of file
+--- /dev/null
++++ b/new.py
+@@ -0,0 +1,2 @@
++# HACK: quick
++print("# TODO: not a comment")
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-old
++TODO: plain text
+`
+
+// Test_Patch тестирует поиск блоков в изменениях формата unified diff. Номера
+// строк добавленных и изменённых блоков указываются в новом файле,
+// удалённых в старом, изменения выводятся по файлам, а блок, начинающийся в
+// строке контекста, должен считаться изменённым.
+func Test_Patch(t *testing.T) {
+	header := "patch:"
+	var stdout, stderr strings.Builder
+	code := runPatch(nil, strings.NewReader(testPatch), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("%s код завершения: %d, %s", header, code, stderr.String())
+	}
+	got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	want := []string{
+		"~ lib/lib.go:11:2: FIXME: fix bug in lexer",
+		"+ lib/lib.go:14:2: TODO(bob): new block spanning lines",
+		"- lib/lib.go:14:2: TODO: gone",
+		"+ db/schema.sql:1:1: NOTE: new comment",
+		"+ new.py:1:1: HACK: quick"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+
+	for _, patch := range []string{"--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n x\n",
+		"--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n*bad\n", "--- a/x.go\n@@ bad @@\n"} {
+		if _, err := PatchTodos(strings.NewReader(patch), DefaultTags, Syntaxes); err == nil {
+			t.Errorf("%s нет ошибки для %q", header, patch)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// relPath возвращает путь к файлу относительно рабочей директории. Если
+// относительный путь получить не удалось, возвращается исходный путь.
+func relPath(wd string, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil || wd == "" {
+		return file
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return file
+	}
+	return rel
+}
+
+// quickfix форматирует блок как строку сообщения компилятора вида
+// file:line:col: TAG: text. Строки блока объединяются через пробел, колонка
+// указывает на начало комментария. Сведения из скобок после тега выводятся
+// после тега как в исходном тексте, TAG(alice, p1): text, а сведения о
+// коммите добавляются в конце строки в квадратных скобках.
+func (td Todos) quickfix(wd string) string {
+	text := make([]string, 0, len(td.lines))
+	for _, line := range td.lines {
+		if line = strings.TrimSpace(line); line != "" {
+			text = append(text, line)
+		}
+	}
+	col := td.col
+	if col < 1 {
+		col = 1
+	}
+	switch {
+	case td.blame.author != "":
+		text = append(text, "["+td.blame.author+" <"+td.blame.email+"> "+
+			td.blame.time.Format("2006-01-02")+" "+shortCommit(td.blame.commit)+"]")
+	case td.blame.commit != "":
+		text = append(text, "["+td.blame.commit+"]")
+	}
+	tag := td.tag
+	if !td.meta.IsZero() {
+		tag += "(" + td.meta.String() + ")"
+	}
+	return relPath(wd, td.file) + ":" + strconv.Itoa(td.line) + ":" +
+		strconv.Itoa(col) + ": " + tag + ": " + strings.Join(text, " ")
+}
+
+// WriteQuickfix выводит по одной строке на блок в формате сообщений
+// компилятора, который понимают quickfix vim и compilation-mode emacs. Пути к
+// файлам указываются относительно рабочей директории.
+func WriteQuickfix(w io.Writer, todos []Todos) error {
+	wd, _ := os.Getwd()
+	bw := bufio.NewWriter(w)
+	for _, td := range todos {
+		bw.WriteString(td.quickfix(wd) + "\n")
+	}
+	return bw.Flush()
+}
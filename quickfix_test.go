@@ -0,0 +1,20 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import "testing"
+
+// Test_Quickfix тестирует форматирование блока как сообщения компилятора. Путь
+// должен указываться относительно рабочей директории, а строки блока
+// объединяться в одну строку.
+func Test_Quickfix(t *testing.T) {
+	td := NewTodos("FIXME", " broken", "/src/hello/lib/lib.go", 7)
+	td.AppendLine("   and slow")
+	td.col = 5
+
+	want := "lib/lib.go:7:5: FIXME: broken and slow"
+	if got := td.quickfix("/src/hello"); got != want {
+		t.Errorf("quickfix: строки не равны: требуется %s, имеется %s", want, got)
+	}
+}
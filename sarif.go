@@ -0,0 +1,192 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sarifSchema ссылка на JSON схему формата SARIF 2.1.0
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifRootBase название базового URI корня проекта в SARIF
+const sarifRootBase = "PROJECTROOT"
+
+// sarifLog корневой объект формата SARIF 2.1.0. Структуры формата описывают
+// только используемые поля.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevel возвращает уровень результата SARIF для тега
+func sarifLevel(tag string) string {
+	if tag == "BUG" || tag == "FIXME" {
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifProperties возвращает сведения о коммите и сведения из скобок после
+// тега как свойства результата SARIF. Списки объединяются через запятую.
+func sarifProperties(b Blame, m Meta) map[string]string {
+	props := map[string]string{}
+	if b.commit != "" {
+		props["commit"] = b.commit
+	}
+	if b.author != "" {
+		props["author"] = b.author
+		props["email"] = b.email
+		props["time"] = b.time.Format(time.RFC3339)
+	}
+	if len(m.owners) > 0 {
+		props["owners"] = strings.Join(m.owners, ",")
+	}
+	if len(m.issues) > 0 {
+		props["issues"] = strings.Join(m.issues, ",")
+	}
+	if m.priority > 0 {
+		props["priority"] = "p" + strconv.Itoa(m.priority)
+	}
+	if !m.due.IsZero() {
+		props["due"] = m.due.Format(DueLayout)
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return props
+}
+
+// fileURI возвращает URI для пути файловой системы. Относительные пути
+// возвращаются относительным URI.
+func fileURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+	}
+	return u.String()
+}
+
+// newSarifRun возвращает запуск SARIF для блоков одного проекта. Каждому тегу
+// соответствует правило, каждому блоку результат с положением файла
+// относительно корня проекта.
+func newSarifRun(project string, todos []Todos) sarifRun {
+	run := sarifRun{
+		Tool: sarifTool{sarifDriver{Name: "todolist",
+			InformationURI: "https://github.com/vsratobury/todolist",
+			Rules:          make([]sarifRule, 0)}},
+		Results: make([]sarifResult, 0)}
+	if abs, err := filepath.Abs(project); err == nil && project != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactURI{
+			sarifRootBase: {URI: fileURI(abs) + "/"}}
+	}
+
+	rules := map[string]int{}
+	for _, td := range todos {
+		idx, ok := rules[td.tag]
+		if !ok {
+			idx = len(run.Tool.Driver.Rules)
+			rules[td.tag] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID: td.tag, Name: td.tag,
+				ShortDescription: sarifMessage{td.tag + " comment"}})
+		}
+
+		location := sarifArtifactURI{URI: fileURI(td.file)}
+		if run.OriginalURIBaseIDs != nil {
+			if rel, err := filepath.Rel(project, td.file); err == nil {
+				location = sarifArtifactURI{fileURI(rel), sarifRootBase}
+			}
+		}
+		text := strings.TrimSpace(strings.Join(td.lines, "\n"))
+		if text == "" {
+			text = td.tag
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID: td.tag, RuleIndex: idx, Level: sarifLevel(td.tag),
+			Message: sarifMessage{text},
+			Locations: []sarifLocation{{sarifPhysicalLocation{location,
+				sarifRegion{td.line}}}},
+			Properties: sarifProperties(td.blame, td.meta)})
+	}
+	return run
+}
+
+// WriteSARIF выводит список блоков в формате SARIF 2.1.0. Для каждого проекта
+// создаётся отдельный запуск, пути файлов указываются относительно корня
+// проекта.
+func WriteSARIF(w io.Writer, todos []Todos) error {
+	log := sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: make([]sarifRun, 0)}
+	for start := 0; start < len(todos); {
+		end := start + 1
+		for end < len(todos) && todos[end].project == todos[start].project {
+			end++
+		}
+		log.Runs = append(log.Runs, newSarifRun(todos[start].project, todos[start:end]))
+		start = end
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
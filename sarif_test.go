@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test_SARIF тестирует вывод в формате SARIF 2.1.0. Для каждого проекта должен
+// быть создан отдельный запуск, каждому тегу соответствовать правило, а путь
+// файла результата указываться относительно корня проекта.
+func Test_SARIF(t *testing.T) {
+	header := "sarif:"
+	data := []Todos{
+		NewTodos("TODO", " in hello", "/src/hello/main.go", 2),
+		NewTodos("FIXME", " in lib", "/src/hello/lib/lib.go", 7),
+		NewTodos("TODO", " in world", "/src/world/main.go", 1)}
+	data[0].project = "/src/hello"
+	data[1].project = "/src/hello"
+	data[2].project = "/src/world"
+
+	var b strings.Builder
+	if err := WriteSARIF(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(b.String()), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" {
+		t.Errorf("%s версия: требуется: 2.1.0, имеется: %s", header, log.Version)
+	}
+	if guardLenght(t, header, 2, len(log.Runs)) {
+		t.Fatal(log.Runs)
+	}
+
+	run := log.Runs[0]
+	if root := run.OriginalURIBaseIDs[sarifRootBase].URI; root != "file:///src/hello/" {
+		t.Errorf("%s корень проекта: имеется: %s", header, root)
+	}
+	got := make([]string, 0)
+	for _, r := range run.Results {
+		loc := r.Locations[0].PhysicalLocation
+		got = append(got, r.RuleID+" "+run.Tool.Driver.Rules[r.RuleIndex].ID+" "+
+			loc.ArtifactLocation.URIBaseID+" "+loc.ArtifactLocation.URI+" "+
+			r.Level+" "+r.Message.Text)
+	}
+	want := []string{
+		"TODO TODO PROJECTROOT main.go note in hello",
+		"FIXME FIXME PROJECTROOT lib/lib.go warning in lib"}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	compareStrings(t, header, want, got)
+}
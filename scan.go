@@ -0,0 +1,219 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ScanOptions параметры поиска блоков комментариев
+type ScanOptions struct {
+	markers  []string // маркеры проекта
+	include  []string // шаблоны файлов, nil для шаблонов всех форматов
+	exclude  []string // исключения в синтаксисе .gitignore
+	tags     []string // теги блоков
+	syntaxes []Syntax // форматы файлов
+	nested   bool     // искать проекты вложенные в другие проекты
+	blame    bool     // получать сведения о коммитах для проектов git
+	jobs     int      // количество одновременно работающих горутин
+	cache    *Cache   // кэш комментариев файлов, nil если кэш не используется
+	cli      *Config  // настройки командной строки, важнее файлов настроек
+}
+
+// DefaultScanOptions возвращает параметры поиска по умолчанию. Количество
+// горутин равно GOMAXPROCS, сведения о коммитах получаются.
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		markers:  []string{".git", "go.mod", "Makefile"},
+		tags:     DefaultTags,
+		syntaxes: Syntaxes,
+		blame:    true,
+		jobs:     runtime.GOMAXPROCS(0),
+	}
+}
+
+// patterns возвращает шаблоны файлов для поиска
+func (opt ScanOptions) patterns() []string {
+	if opt.include != nil {
+		return opt.include
+	}
+	return syntaxPatterns(opt.syntaxes)
+}
+
+// parallel вызывает fn для каждого индекса от 0 до count, используя не более
+// jobs горутин одновременно. Возвращает управление после завершения всех
+// вызовов.
+func parallel(jobs int, count int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > count {
+		jobs = count
+	}
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for j := 0; j < jobs; j++ {
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+}
+
+// scanJob файл проекта для поиска блоков комментариев
+type scanJob struct {
+	project string       // путь к проекту
+	file    string       // путь к файлу
+	git     bool         // проект является репозиторием git
+	head    string       // коммит HEAD репозитория проекта для кэша git blame
+	opt     *ScanOptions // параметры поиска с настройками проекта
+}
+
+// joinBase возвращает путь в файловой системе для пути p внутри fs.FS,
+// корень которого находится в base. Пустой base оставляет путь без изменений.
+// Если base путь к архиву с разделителем ArchiveSep в конце, путь внутри
+// архива добавляется после разделителя, а корень архива это путь к архиву.
+func joinBase(base string, p string) string {
+	if base == "" {
+		return p
+	}
+	if strings.HasSuffix(base, ArchiveSep) {
+		if p == "." {
+			return strings.TrimSuffix(base, ArchiveSep)
+		}
+		return base + filepath.FromSlash(p)
+	}
+	return filepath.Join(base, filepath.FromSlash(p))
+}
+
+// relBase возвращает путь p, полученный joinBase, относительно корня base в
+// формате fs.FS. Путь не начинающийся с base возвращается без изменений.
+func relBase(base string, p string) string {
+	if base == "" {
+		return filepath.ToSlash(p)
+	}
+	if strings.HasSuffix(base, ArchiveSep) {
+		if p == strings.TrimSuffix(base, ArchiveSep) {
+			return "."
+		}
+		if strings.HasPrefix(p, base) {
+			return filepath.ToSlash(p[len(base):])
+		}
+		return filepath.ToSlash(p)
+	}
+	rel, err := filepath.Rel(base, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// scanFile находит блоки комментариев в одном файле проекта. Пути в
+// найденных блоках начинаются с base.
+func scanFile(fsd fs.FS, base string, job scanJob) ([]Todos, []error) {
+	errs := make([]error, 0)
+	opt := job.opt
+	cs, ok := findSyntax(opt.syntaxes, job.file)
+	if !ok {
+		return []Todos{}, errs
+	}
+	file, project := joinBase(base, job.file), joinBase(base, job.project)
+	key, err := filepath.Abs(file)
+	if err != nil {
+		key = file
+	}
+	comments, err := opt.cache.FindComments(fsd, job.file, key, cs)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	todos, err := FindTodos(file, comments, opt.tags)
+	errs = appendErrors(errs, err)
+	for i := range todos {
+		todos[i].project, todos[i].root = project, base
+	}
+	if job.git {
+		if err := opt.cache.BlameTodos(project, file, key, job.head, todos); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return todos, errs
+}
+
+// Scan находит проекты в директории dir, файлы проектов и блоки комментариев в
+// них. Поиск файлов и разбор файлов выполняются параллельно не более чем
+// opt.jobs горутинами, порядок результатов не зависит от порядка их
+// завершения: проекты в порядке FindProjects, файлы в порядке FindFiles.
+// Настройки из файла настроек проекта применяются поверх opt к файлам
+// проекта. Файлы вложенного проекта относятся только к вложенному проекту.
+// Директория dir добавляется в корни поиска кэша opt.cache. Возвращает
+// найденные блоки и все ошибки возникшие при поиске.
+func Scan(fsd fs.FS, base string, dir string, opt ScanOptions) ([]Todos, []error) {
+	errs := make([]error, 0)
+	if root, err := filepath.Abs(joinBase(base, dir)); err == nil {
+		opt.cache.AddRoot(root)
+	}
+	find := FindProjects
+	if opt.nested {
+		find = FindNestedProjects
+	}
+	prjlist, err := find(fsd, dir, opt.markers)
+	errs = appendErrors(errs, err)
+	// файл принадлежит ближайшему проекту, вложенные проекты пропускаются
+	projects := map[string]bool{}
+	for _, prj := range prjlist {
+		projects[prj] = true
+	}
+
+	fileslist := make([][]string, len(prjlist))
+	fileerrs := make([][]error, len(prjlist))
+	prjopts := make([]ScanOptions, len(prjlist))
+	parallel(opt.jobs, len(prjlist), func(i int) {
+		cfg, err := LoadConfig(fsd, prjlist[i])
+		if err != nil {
+			fileerrs[i] = append(fileerrs[i], err)
+		}
+		prjopts[i] = opt.Apply(cfg).Apply(opt.cli)
+		ig := IgnoreFor(fsd, dir, prjlist[i]).With(prjlist[i], prjopts[i].exclude)
+		fileslist[i], err = findFiles(fsd, prjlist[i], prjopts[i].patterns(), ig, projects)
+		fileerrs[i] = appendErrors(fileerrs[i], err)
+	})
+
+	jobs := make([]scanJob, 0)
+	for i, prj := range prjlist {
+		errs = append(errs, fileerrs[i]...)
+		_, err := fs.Stat(fsd, filepath.Join(prj, ".git"))
+		git, head := err == nil && opt.blame, ""
+		if git && opt.cache != nil {
+			head, _ = gitHead(joinBase(base, prj))
+		}
+		for _, file := range fileslist[i] {
+			jobs = append(jobs, scanJob{prj, file, git, head, &prjopts[i]})
+		}
+	}
+
+	todos := make([][]Todos, len(jobs))
+	scanerrs := make([][]error, len(jobs))
+	parallel(opt.jobs, len(jobs), func(i int) {
+		todos[i], scanerrs[i] = scanFile(fsd, base, jobs[i])
+	})
+
+	result := make([]Todos, 0)
+	for i := range jobs {
+		result = append(result, todos[i]...)
+		errs = append(errs, scanerrs[i]...)
+	}
+	return result, errs
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Test_Scan тестирует параллельный поиск блоков комментариев в тестовой
+// директории. Результат не должен зависеть от количества горутин: блоки
+// выводятся в порядке проектов и файлов.
+func Test_Scan(t *testing.T) {
+	header := "поиск:"
+	want := []string{"testdata/hello/main_hello.go:2",
+		"testdata/world/main_world.go:1"}
+
+	for _, jobs := range []int{1, 2, 8} {
+		opt := DefaultScanOptions()
+		opt.jobs = jobs
+		todos, errs := Scan(os.DirFS("."), "", "testdata", opt)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		got := make([]string, 0)
+		for i := range todos {
+			got = append(got, todos[i].position)
+		}
+		if guardLenght(t, header, len(want), len(got)) {
+			t.Fatal(got)
+		}
+		compareStrings(t, header, want, got)
+	}
+}
@@ -0,0 +1,446 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// todoFilter отбор блоков по параметрам запроса. Пустое поле не
+// ограничивает отбор.
+type todoFilter struct {
+	Project string // путь к проекту
+	Tag     string // тег блока без учёта регистра
+	Owner   string // один из ответственных без учёта регистра
+	File    string // часть пути к файлу
+}
+
+// newTodoFilter возвращает отбор по параметрам запроса project, tag, owner и
+// file
+func newTodoFilter(q url.Values) todoFilter {
+	return todoFilter{Project: q.Get("project"), Tag: q.Get("tag"),
+		Owner: strings.TrimPrefix(q.Get("owner"), "@"), File: q.Get("file")}
+}
+
+// match возвращает true если блок проходит отбор
+func (f todoFilter) match(td Todos) bool {
+	if f.Project != "" && td.project != f.Project {
+		return false
+	}
+	if f.Tag != "" && !strings.EqualFold(td.tag, f.Tag) {
+		return false
+	}
+	if f.File != "" && !strings.Contains(filepath.ToSlash(td.file), filepath.ToSlash(f.File)) {
+		return false
+	}
+	if f.Owner == "" {
+		return true
+	}
+	for _, owner := range td.meta.owners {
+		if strings.EqualFold(owner, f.Owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter возвращает блоки, которые проходят отбор
+func (f todoFilter) filter(todos []Todos) []Todos {
+	result := make([]Todos, 0, len(todos))
+	for _, td := range todos {
+		if f.match(td) {
+			result = append(result, td)
+		}
+	}
+	return result
+}
+
+// projectJSON представление сводки по проекту в JSON
+type projectJSON struct {
+	Project string         `json:"project"` // путь к проекту
+	Todos   int            `json:"todos"`   // количество блоков
+	Tags    map[string]int `json:"tags"`    // количество блоков по тегам
+}
+
+// summarizeProjects возвращает количество блоков в проектах, в том числе по
+// тегам, отсортированное по пути к проекту
+func summarizeProjects(todos []Todos) []projectJSON {
+	index := map[string]int{}
+	result := make([]projectJSON, 0)
+	for _, td := range todos {
+		i, ok := index[td.project]
+		if !ok {
+			i = len(result)
+			index[td.project] = i
+			result = append(result, projectJSON{Project: td.project, Tags: map[string]int{}})
+		}
+		result[i].Todos++
+		result[i].Tags[td.tag]++
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Project < result[j].Project })
+	return result
+}
+
+// todoServer сервер HTTP со списком блоков. Поиск выполняется теми же
+// параметрами, что и в командной строке, и повторяется если результат
+// старше interval, при нулевом interval при каждом запросе. Параметр
+// запроса refresh повторяет поиск независимо от interval.
+type todoServer struct {
+	sf       *scanFlags
+	roots    []string
+	cfgs     []*Config
+	interval time.Duration
+	errw     io.Writer // вывод ошибок поиска и обработки запросов
+
+	mu      sync.Mutex // защищает результат поиска
+	todos   []Todos
+	errs    []error
+	scanned time.Time // время последнего поиска
+}
+
+// result возвращает результат поиска, повторяя поиск если он устарел или
+// force равно true
+func (srv *todoServer) result(force bool) ([]Todos, []error, time.Time) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if force || srv.scanned.IsZero() || time.Since(srv.scanned) >= srv.interval {
+		srv.todos, srv.errs = srv.sf.scan(srv.roots, srv.cfgs, srv.errw)
+		srv.scanned = time.Now()
+	}
+	return srv.todos, srv.errs, srv.scanned
+}
+
+// request возвращает результат поиска и отбор для запроса r
+func (srv *todoServer) request(r *http.Request) ([]Todos, []error, time.Time, todoFilter) {
+	q := r.URL.Query()
+	_, force := q["refresh"]
+	todos, errs, scanned := srv.result(force)
+	return todos, errs, scanned, newTodoFilter(q)
+}
+
+// handler возвращает обработчик запросов: страницу со списком блоков «/»,
+// страницу файла с блоком «/source» и JSON «/api/todos» и «/api/projects»
+func (srv *todoServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", srv.serveDashboard)
+	mux.HandleFunc("/source", srv.serveSource)
+	mux.HandleFunc("/api/todos", srv.serveTodos)
+	mux.HandleFunc("/api/projects", srv.serveProjects)
+	return mux
+}
+
+// serveTodos выводит отобранные блоки и ошибки поиска в формате WriteJSON
+func (srv *todoServer) serveTodos(w http.ResponseWriter, r *http.Request) {
+	todos, errs, _, f := srv.request(r)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := WriteJSONErrors(w, f.filter(todos), errs); err != nil {
+		fmt.Fprintln(srv.errw, err)
+	}
+}
+
+// serveProjects выводит сводку по проектам отобранных блоков
+func (srv *todoServer) serveProjects(w http.ResponseWriter, r *http.Request) {
+	todos, _, _, f := srv.request(r)
+	report := struct {
+		Version  int           `json:"version"`  // версия схемы
+		Projects []projectJSON `json:"projects"` // сводка по проектам
+	}{SchemaVersion, summarizeProjects(f.filter(todos))}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(srv.errw, err)
+	}
+}
+
+// dashboardItem блок на странице со списком блоков
+type dashboardItem struct {
+	Position string // путь к файлу и номер строки
+	Link     string // ссылка на строку блока на странице файла
+	Project  string
+	Tag      string
+	Text     string // строки блока через пробел
+	Meta     string // сведения из скобок после тега
+}
+
+// dashboard данные страницы со списком блоков
+type dashboard struct {
+	Filter   todoFilter
+	Projects []string // значения отбора по проекту
+	Tags     []string // значения отбора по тегу
+	Owners   []string // значения отбора по ответственному
+	Items    []dashboardItem
+	Total    int      // количество блоков без отбора
+	Errors   []string // ошибки поиска
+	Scanned  string   // время поиска
+}
+
+// sortedKeys возвращает отсортированные ключи множества
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sourceLink возвращает ссылку на строку блока на странице файла
+func sourceLink(td Todos) string {
+	return "/source?" + url.Values{"file": {td.file}}.Encode() + "#L" + strconv.Itoa(td.line)
+}
+
+// serveDashboard выводит страницу со списком отобранных блоков и формой
+// отбора
+func (srv *todoServer) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	todos, errs, scanned, f := srv.request(r)
+	projects, tags, owners := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, td := range todos {
+		projects[td.project] = true
+		tags[td.tag] = true
+		for _, owner := range td.meta.owners {
+			owners[owner] = true
+		}
+	}
+	page := dashboard{Filter: f, Projects: sortedKeys(projects), Tags: sortedKeys(tags),
+		Owners: sortedKeys(owners), Items: make([]dashboardItem, 0), Total: len(todos),
+		Scanned: scanned.Format("2006-01-02 15:04:05")}
+	for _, td := range f.filter(todos) {
+		text := make([]string, 0, len(td.lines))
+		for _, line := range td.lines {
+			if line = strings.TrimSpace(line); line != "" {
+				text = append(text, line)
+			}
+		}
+		page.Items = append(page.Items, dashboardItem{Position: td.position,
+			Link: sourceLink(td), Project: td.project, Tag: td.tag,
+			Text: strings.Join(text, " "), Meta: td.meta.String()})
+	}
+	for _, err := range errs {
+		page.Errors = append(page.Errors, err.Error())
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		fmt.Fprintln(srv.errw, err)
+	}
+}
+
+// readSource читает файл с блоками из файловой системы корня поиска, которому
+// принадлежит путь file
+func (srv *todoServer) readSource(file string) ([]byte, error) {
+	for i, base := range srv.sf.bases {
+		var rel string
+		if strings.HasSuffix(base, ArchiveSep) {
+			if !strings.HasPrefix(file, base) {
+				continue
+			}
+			rel = file[len(base):]
+		} else {
+			var err error
+			if rel, err = filepath.Rel(base, file); err != nil {
+				continue
+			}
+		}
+		rel = filepath.ToSlash(rel)
+		if !fs.ValidPath(rel) {
+			continue
+		}
+		if data, err := fs.ReadFile(srv.sf.fss[i], rel); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// source данные страницы файла
+type source struct {
+	File  string
+	Lines []string
+}
+
+// serveSource выводит страницу файла с номерами строк, на которые ссылаются
+// блоки со страницы со списком. Выводятся только файлы, в которых найдены
+// блоки.
+func (srv *todoServer) serveSource(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	todos, _, _ := srv.result(false)
+	found := false
+	for _, td := range todos {
+		found = found || td.file == file
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := srv.readSource(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	page := source{File: file, Lines: strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sourceTemplate.Execute(w, page); err != nil {
+		fmt.Fprintln(srv.errw, err)
+	}
+}
+
+// pageStyle оформление страниц сервера
+const pageStyle = `<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+tr:nth-child(even) { background: #f4f4f4; }
+.tag { font-weight: bold; }
+.meta, .muted { color: #666; }
+pre { margin: 0; }
+.source td:first-child { color: #999; text-align: right; user-select: none; }
+.source tr:target { background: #ffe98a; }
+</style>`
+
+// dashboardTemplate шаблон страницы со списком блоков
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>todolist</title>
+` + pageStyle + `
+</head>
+<body>
+<h1>todolist</h1>
+<form method="get" action="/">
+<label>Проект <select name="project"><option value="">все</option>
+{{- range .Projects}}<option{{if eq . $.Filter.Project}} selected{{end}}>{{.}}</option>{{end -}}
+</select></label>
+<label>Тег <select name="tag"><option value="">все</option>
+{{- range .Tags}}<option{{if eq . $.Filter.Tag}} selected{{end}}>{{.}}</option>{{end -}}
+</select></label>
+<label>Ответственный <select name="owner"><option value="">все</option>
+{{- range .Owners}}<option{{if eq . $.Filter.Owner}} selected{{end}}>{{.}}</option>{{end -}}
+</select></label>
+<label>Файл <input name="file" value="{{.Filter.File}}"></label>
+<button type="submit">Показать</button>
+<button type="submit" name="refresh" value="1">Обновить</button>
+</form>
+<p class="muted">Показано {{len .Items}} из {{.Total}}, поиск {{.Scanned}}</p>
+{{- if .Errors}}
+<details><summary>Ошибки поиска: {{len .Errors}}</summary><ul>
+{{- range .Errors}}<li>{{.}}</li>{{end}}</ul></details>
+{{- end}}
+<table>
+<tr><th>Положение</th><th>Тег</th><th>Текст</th><th>Проект</th></tr>
+{{- range .Items}}
+<tr><td><a href="{{.Link}}">{{.Position}}</a></td><td class="tag">{{.Tag}}</td>
+<td>{{.Text}}{{if .Meta}} <span class="meta">({{.Meta}})</span>{{end}}</td><td>{{.Project}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// sourceTemplate шаблон страницы файла
+var sourceTemplate = template.Must(template.New("source").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>{{.File}}</title>
+` + pageStyle + `
+</head>
+<body>
+<p><a href="/">← todolist</a></p>
+<h1>{{.File}}</h1>
+<table class="source">
+{{- range $i, $line := .Lines}}
+<tr id="L{{inc $i}}"><td>{{inc $i}}</td><td><pre>{{$line}}</pre></td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// runServe выполняет команду serve: запускает сервер HTTP со списком блоков
+// до получения сигнала SIGINT или SIGTERM
+func runServe(args []string, stdout io.Writer, stderr io.Writer) int {
+	fset := flag.NewFlagSet("todolist serve", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), `Использование: todolist serve [флаги] [директория или архив ...]
+
+Запускает сервер HTTP со списком блоков и отбором по проекту, тегу,
+ответственному и файлу. Список в JSON доступен по адресам /api/todos и
+/api/projects с теми же параметрами отбора project, tag, owner и file.
+
+Флаги:`)
+		fset.PrintDefaults()
+	}
+	sf := newScanFlags(fset)
+	sf.revFlag()
+	addr := fset.String("addr", "localhost:8080", "адрес сервера")
+	interval := fset.Duration("interval", 10*time.Second,
+		"повторять поиск если результат старше интервала, 0 при каждом запросе")
+	if err := fset.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *interval < 0 {
+		fmt.Fprintln(stderr, "интервал не может быть отрицательным:", *interval)
+		return exitUsage
+	}
+
+	roots, cfgs, code := sf.setup(fset.Args(), stderr)
+	if code != exitOK {
+		return code
+	}
+	defer sf.close(stderr)
+	// изменившиеся файлы разбираются заново, остальные берутся из кэша
+	if sf.opt.cache == nil && sf.rev == "" {
+		sf.opt.cache = newCache("")
+	}
+
+	srv := &todoServer{sf: sf, roots: roots, cfgs: cfgs, interval: *interval, errw: stderr}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	server := &http.Server{Handler: srv.handler()}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		server.Close()
+	}()
+	fmt.Fprintf(stdout, "http://%s/\n", ln.Addr())
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
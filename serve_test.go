@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer возвращает сервер с поиском в директории dir без кэша
+func newTestServer(t *testing.T, dir string) *todoServer {
+	t.Helper()
+	fset := flag.NewFlagSet("todolist serve", flag.ContinueOnError)
+	sf := newScanFlags(fset)
+	if err := fset.Parse([]string{"-no-cache", dir}); err != nil {
+		t.Fatal(err)
+	}
+	roots, cfgs, code := sf.setup(fset.Args(), io.Discard)
+	if code != exitOK {
+		t.Fatalf("код завершения: %d", code)
+	}
+	return &todoServer{sf: sf, roots: roots, cfgs: cfgs, errw: io.Discard}
+}
+
+// get выполняет запрос к серверу и возвращает код ответа и тело ответа
+func get(srv *todoServer, target string) (int, string) {
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec.Code, rec.Body.String()
+}
+
+// Test_Serve тестирует сервер со списком блоков. Отбор по проекту, тегу,
+// ответственному и файлу должен одинаково работать на странице и в JSON,
+// страница должна ссылаться на строку блока в файле, а поиск повторяться
+// при каждом запросе с нулевым интервалом.
+func Test_Serve(t *testing.T) {
+	header := "сервер:"
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/go.mod":  "module app\n",
+		"app/main.go": "package main\n\n// TODO(alice, p1): first <b>\n// FIXME: second\n",
+		"lib/go.mod":  "module lib\n",
+		"lib/lib.go":  "package lib\n// TODO(@bob): third\n",
+	}
+	for name, text := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := newTestServer(t, dir)
+
+	for _, c := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"first <b>", "second", "third"}},
+		{"?tag=todo", []string{"first <b>", "third"}},
+		{"?owner=bob", []string{"third"}},
+		{"?project=" + filepath.Join(dir, "app") + "&tag=FIXME", []string{"second"}},
+		{"?file=lib.go", []string{"third"}},
+	} {
+		code, body := get(srv, "/api/todos"+c.query)
+		var report struct {
+			Todos []Todos `json:"todos"`
+		}
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &report) != nil {
+			t.Fatalf("%s %s: код %d, %s", header, c.query, code, body)
+		}
+		got := make([]string, 0, len(report.Todos))
+		for _, td := range report.Todos {
+			got = append(got, strings.TrimSpace(strings.Join(td.lines, " ")))
+		}
+		if guardLenght(t, header+" "+c.query, len(c.want), len(got)) {
+			continue
+		}
+		compareStrings(t, header+" "+c.query, c.want, got)
+	}
+
+	code, body := get(srv, "/?owner=alice")
+	link := "/source?file=" + strings.ReplaceAll(filepath.Join(dir, "app", "main.go"), "/", "%2F") + "#L3"
+	if code != http.StatusOK || !strings.Contains(body, `href="`+link+`"`) ||
+		!strings.Contains(body, "first &lt;b&gt;") || strings.Contains(body, "third") {
+		t.Errorf("%s страница: код %d, нет ссылки %s\n%s", header, code, link, body)
+	}
+	code, body = get(srv, link[:strings.Index(link, "#")])
+	if code != http.StatusOK || !strings.Contains(body, `id="L3"`) {
+		t.Errorf("%s файл: код %d\n%s", header, code, body)
+	}
+	if code, _ = get(srv, "/source?file="+filepath.Join(dir, "app", "go.mod")); code != http.StatusNotFound {
+		t.Errorf("%s файл без блоков: код %d", header, code)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "lib", "new.go"), []byte("// HACK: new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, body = get(srv, "/api/projects")
+	var projects struct {
+		Projects []projectJSON `json:"projects"`
+	}
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &projects) != nil ||
+		len(projects.Projects) != 2 {
+		t.Fatalf("%s проекты: код %d, %s", header, code, body)
+	}
+	if p := projects.Projects[1]; p.Project != filepath.Join(dir, "lib") || p.Todos != 2 ||
+		p.Tags["HACK"] != 1 {
+		t.Errorf("%s проекты: %+v", header, p)
+	}
+}
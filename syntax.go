@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import "path/filepath"
+
+// Syntax сопоставляет список файловых шаблонов символам комментариев формата
+// файла.
+type Syntax struct {
+	name     string         // название формата
+	patterns []string       // файловые шаблоны имени файла
+	simbols  CommentSimbols // символы комментариев формата
+}
+
+// goSimbols символы комментариев Go. Файлы Go с этими символами разбираются
+// go/scanner, с другими символами, заданными в настройках, общим лексером.
+var goSimbols = CommentSimbols{"//", "/*", "*/", `"'`, "`", ""}
+
+// Syntaxes реестр известных форматов файлов. Формат файла определяется по
+// первому совпавшему файловому шаблону.
+var Syntaxes = []Syntax{
+	{"go", []string{"*.go", "go.mod", "go.work"}, goSimbols},
+	{"c", []string{"*.c", "*.h"}, CommentSimbols{"//", "/*", "*/", `"'`, "", ""}},
+	{"c++", []string{"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx"},
+		CommentSimbols{"//", "/*", "*/", `"'`, "", ""}},
+	{"java", []string{"*.java", "*.kt", "*.scala"},
+		CommentSimbols{"//", "/*", "*/", `"'`, "", ""}},
+	{"javascript", []string{"*.js", "*.mjs", "*.cjs", "*.jsx", "*.ts", "*.tsx"},
+		CommentSimbols{"//", "/*", "*/", `"'`, "", "`"}},
+	{"python", []string{"*.py"},
+		CommentSimbols{"#", "", "", `"'`, "", `""" '''`}},
+	{"shell", []string{"*.sh", "*.bash", "*.zsh"},
+		CommentSimbols{"#", "", "", `"`, "'", ""}},
+	// кавычки в make не образуют строк, апостроф в тексте команды, например,
+	// echo Don't, не должен скрывать следующие комментарии
+	{"make", []string{"Makefile", "makefile", "GNUmakefile", "*.mk"},
+		CommentSimbols{"#", "", "", "", "", ""}},
+	{"yaml", []string{"*.yaml", "*.yml"}, CommentSimbols{"#", "", "", `"'`, "", ""}},
+	{"toml", []string{"*.toml"}, CommentSimbols{"#", "", "", `"'`, "", ""}},
+	{"sql", []string{"*.sql"}, CommentSimbols{"--", "/*", "*/", `"'`, "", ""}},
+	{"lua", []string{"*.lua"}, CommentSimbols{"--", "--[[", "]]", `"'`, "", ""}},
+	{"html", []string{"*.html", "*.htm", "*.xml", "*.svg"},
+		CommentSimbols{"", "<!--", "-->", "", "", ""}},
+	{"markdown", []string{"*.md", "*.markdown"},
+		CommentSimbols{"", "<!--", "-->", "", "", ""}},
+}
+
+// SyntaxPatterns возвращает файловые шаблоны всех форматов из реестра.
+func SyntaxPatterns() []string {
+	return syntaxPatterns(Syntaxes)
+}
+
+// FindSyntax возвращает символы комментариев для файла по его имени. Если
+// формат файла не известен, второе значение равно false.
+func FindSyntax(file string) (CommentSimbols, bool) {
+	return findSyntax(Syntaxes, file)
+}
+
+// syntaxPatterns возвращает файловые шаблоны всех форматов списка
+func syntaxPatterns(list []Syntax) []string {
+	result := make([]string, 0)
+	for i := range list {
+		result = append(result, list[i].patterns...)
+	}
+	return result
+}
+
+// findSyntax возвращает символы комментариев первого формата списка, шаблон
+// которого совпадает с именем файла.
+func findSyntax(list []Syntax, file string) (CommentSimbols, bool) {
+	name := filepath.Base(file)
+	for i := range list {
+		found, err := isMatchAny(list[i].patterns, name)
+		if err == nil && found {
+			return list[i].simbols, true
+		}
+	}
+	return CommentSimbols{}, false
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+// Test_Syntax тестирует определение символов комментариев по имени файла.
+// Формат файла определяется по первому совпавшему файловому шаблону реестра,
+// для неизвестных форматов функция должна вернуть false.
+func Test_Syntax(t *testing.T) {
+	data := map[string]CommentSimbols{
+		"testdata/hello/main_hello.go": {"//", "/*", "*/", `"'`, "`", ""},
+		"src/lib.hpp":                  {"//", "/*", "*/", `"'`, "", ""},
+		"tools/gen.py":                 {"#", "", "", `"'`, "", `""" '''`},
+		"scripts/build.sh":             {"#", "", "", `"`, "'", ""},
+		"Makefile":                     {"#", "", "", "", "", ""},
+		"rules.mk":                     {"#", "", "", "", "", ""},
+		"db/schema.sql":                {"--", "/*", "*/", `"'`, "", ""},
+		"README.md":                    {"", "<!--", "-->", "", "", ""},
+	}
+	for file, want := range data {
+		got, ok := FindSyntax(file)
+		if !ok {
+			t.Errorf("формат: не найден для %s", file)
+			continue
+		}
+		if got != want {
+			t.Errorf("формат %s: требуется: %v, имеется: %v", file, want, got)
+		}
+	}
+
+	if _, ok := FindSyntax("image.png"); ok {
+		t.Errorf("формат: найден для неизвестного файла image.png")
+	}
+}
+
+// Test_SyntaxFilelist тестирует поиск файлов по шаблонам из реестра форматов.
+func Test_SyntaxFilelist(t *testing.T) {
+	header := "список файлов:"
+
+	got, err := FindFiles(os.DirFS("."), "testdata/hello", SyntaxPatterns())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"testdata/hello/go.mod", "testdata/hello/main_hello.go"}
+
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal("результат:", got)
+	}
+
+	compareStrings(t, header, want, got)
+}
+
+// Test_SyntaxMakefile тестирует поиск комментариев в Makefile. Апостроф в
+// тексте команды не начинает строку, поэтому следующие комментарии должны
+// находиться.
+func Test_SyntaxMakefile(t *testing.T) {
+	header := "комментарии Makefile:"
+	fsd := fstest.MapFS{"Makefile": {Data: []byte(
+		"all:\n\t@echo Don't panic\n\n# TODO: found\nx = 1 # it's fine\n")}}
+	cs, _ := FindSyntax("Makefile")
+	got, err := FindComments(fsd, "Makefile", cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CommentLine{{4, 1, " TODO: found"}, {5, 7, " it's fine"}}
+	if guardLenght(t, header, len(want), len(got)) {
+		t.Fatal(got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s требуется: %v, имеется: %v", header, want[i], got[i])
+		}
+	}
+}
@@ -11,5 +11,8 @@ func hello() {
 * Line four
  */
 func wold() {
-	r := 1 / 3
+	a := "/* in string"
+	r := 1 / 3 /* a */ + 2 // b
+	s := `raw // not comment
+	*/ still string`
 }
@@ -0,0 +1,272 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML разбирает подмножество формата TOML достаточное для файла
+// настроек: пары ключ = значение, таблицы с составными ключами вида
+// [a."b.c"], строки в двойных и одинарных кавычках, целые числа, логические
+// значения и массивы этих значений, в том числе на нескольких строках.
+// Возвращает дерево значений, где таблицы представлены как
+// map[string]interface{}.
+func parseTOML(data string) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	table := root
+	p := &tomlParser{src: data, line: 1}
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return root, nil
+		}
+		if p.peek() == '[' {
+			p.pos++
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume(']') {
+				return nil, p.errorf("ожидается «]»")
+			}
+			if table, err = tomlTable(root, keys); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		} else {
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume('=') {
+				return nil, p.errorf("ожидается «=»")
+			}
+			p.skipSpace(false)
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			parent, err := tomlTable(table, keys[:len(keys)-1])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			last := keys[len(keys)-1]
+			if _, ok := parent[last]; ok {
+				return nil, p.errorf("повторный ключ %q", last)
+			}
+			parent[last] = value
+		}
+		p.skipSpace(false)
+		if !p.eof() && !p.consume('\n') {
+			return nil, p.errorf("ожидается конец строки")
+		}
+		p.line++
+	}
+}
+
+// tomlTable возвращает вложенную таблицу по списку ключей, создавая
+// отсутствующие таблицы.
+func tomlTable(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		next, ok := table[key]
+		if !ok {
+			next = map[string]interface{}{}
+			table[key] = next
+		}
+		sub, ok := next.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ключ %q не является таблицей", key)
+		}
+		table = sub
+	}
+	return table, nil
+}
+
+// tomlParser состояние разбора TOML
+type tomlParser struct {
+	src  string
+	pos  int
+	line int // номер строки для сообщений об ошибках
+}
+
+func (p *tomlParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *tomlParser) peek() byte { return p.src[p.pos] }
+
+// consume пропускает символ c если он следующий
+func (p *tomlParser) consume(c byte) bool {
+	if !p.eof() && p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// errorf возвращает ошибку с номером текущей строки
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("toml: строка %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace пропускает пробелы и комментарии, а если newlines равно true, то
+// и переводы строк.
+func (p *tomlParser) skipSpace(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		default:
+			return
+		}
+	}
+}
+
+// parseKey разбирает простой или составной ключ вида a.b."c.d"
+func (p *tomlParser) parseKey() ([]string, error) {
+	keys := make([]string, 0)
+	for {
+		p.skipSpace(false)
+		if p.eof() {
+			return nil, p.errorf("ожидается ключ")
+		}
+		switch p.peek() {
+		case '"', '\'':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKey(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("ожидается ключ")
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+		p.skipSpace(false)
+		if !p.consume('.') {
+			return keys, nil
+		}
+	}
+}
+
+// isTOMLBareKey проверяет что символ допустим в ключе без кавычек
+func isTOMLBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-'
+}
+
+// parseValue разбирает значение: строку, число, логическое значение или массив
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("ожидается значение")
+	}
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		p.pos++
+		list := make([]interface{}, 0)
+		for {
+			p.skipSpace(true)
+			if p.consume(']') {
+				return list, nil
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			p.skipSpace(true)
+			if p.consume(']') {
+				return list, nil
+			}
+			if !p.consume(',') {
+				return nil, p.errorf("ожидается «,» или «]»")
+			}
+		}
+	default:
+		start := p.pos
+		for !p.eof() && strings.IndexByte(" \t\r\n,]#", p.peek()) < 0 {
+			p.pos++
+		}
+		word := p.src[start:p.pos]
+		switch word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 64)
+		if err != nil {
+			return nil, p.errorf("неизвестное значение %q", word)
+		}
+		return n, nil
+	}
+}
+
+// parseString разбирает строку в двойных кавычках с экранированием или
+// строку в одинарных кавычках без экранирования
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("не закрыта строка")
+		}
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && quote == '"':
+			if p.eof() {
+				return "", p.errorf("не закрыта строка")
+			}
+			esc := p.peek()
+			p.pos++
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(esc)
+			case 'u', 'U':
+				size := 4
+				if esc == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.src) {
+					return "", p.errorf("неверная последовательность \\%c", esc)
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+				if err != nil {
+					return "", p.errorf("неверная последовательность \\%c", esc)
+				}
+				b.WriteRune(rune(r))
+				p.pos += size
+			default:
+				return "", p.errorf("неверная последовательность \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
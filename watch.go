@@ -0,0 +1,307 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notifier сообщает об изменениях в файловой системе. После каждого изменения
+// в канал changes отправляется пустое значение, несколько изменений подряд
+// могут быть объединены в одно.
+type notifier interface {
+	add(dir string) error     // начать наблюдение за директорией
+	changes() <-chan struct{} // канал уведомлений об изменениях
+	close() error             // завершить наблюдение
+}
+
+// watchFilter определяет файлы, изменения которых не требуют повторного
+// поиска: файлы из списка исключений, например, файл отчёта, и файлы и
+// директории исключённые правилами .gitignore и .todoignore. Безопасен для
+// использования из нескольких горутин.
+type watchFilter struct {
+	mu      sync.Mutex
+	exclude map[string]bool    // пути исключённых файлов
+	ignores map[string]*Ignore // правила исключения директорий последнего обхода
+}
+
+// newWatchFilter возвращает фильтр с путями исключённых файлов exclude
+func newWatchFilter(exclude []string) *watchFilter {
+	wf := &watchFilter{exclude: map[string]bool{}, ignores: map[string]*Ignore{}}
+	for _, p := range exclude {
+		wf.exclude[path.Clean(p)] = true
+	}
+	return wf
+}
+
+// walk обходит не скрытые директории и файлы начиная с root, пропуская
+// исключённые, и вызывает fn для каждого из них. Правила исключения
+// директорий сохраняются для skip.
+func (wf *watchFilter) walk(fsd fs.FS, root string, fn func(p string, d fs.DirEntry)) {
+	ignores := map[string]*Ignore{}
+	fs.WalkDir(fsd, root, func(p string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return nil
+		}
+		parent := ignores[path.Dir(p)]
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(d.Name(), ".") || parent.Match(p, true)) {
+				return fs.SkipDir
+			}
+			ignores[p] = parent.Load(fsd, p)
+		} else if wf.exclude[p] || hiddenFile(d.Name()) || parent.Match(p, false) {
+			return nil
+		}
+		fn(p, d)
+		return nil
+	})
+	wf.mu.Lock()
+	wf.ignores = ignores
+	wf.mu.Unlock()
+}
+
+// hiddenFile проверяет что скрытый файл, например, файл подкачки редактора
+// .main.go.swp, не влияет на результат поиска. Файлы правил исключения и
+// файлы настроек скрытыми не считаются.
+func hiddenFile(name string) bool {
+	if !strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, list := range [][]string{IgnoreFiles, ConfigFiles} {
+		for _, special := range list {
+			if name == special {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// skip проверяет что изменение файла или директории p не требует повторного
+// поиска
+func (wf *watchFilter) skip(p string, isDir bool) bool {
+	if !isDir && (wf.exclude[p] || hiddenFile(path.Base(p))) {
+		return true
+	}
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	return wf.ignores[path.Dir(p)].Match(p, isDir)
+}
+
+// pollNotifier обнаруживает изменения периодически сравнивая размер и время
+// изменения файлов. Используется если inotify недоступен.
+type pollNotifier struct {
+	fsd    fs.FS
+	root   string
+	filter *watchFilter
+	ch     chan struct{}
+	done   chan struct{}
+	state  map[string]string // размер и время изменения файлов
+}
+
+// newPollNotifier возвращает наблюдателя проверяющего директорию root с
+// интервалом interval. Файлы исключённые фильтром wf не проверяются.
+func newPollNotifier(fsd fs.FS, root string, interval time.Duration, wf *watchFilter) *pollNotifier {
+	pn := &pollNotifier{fsd: fsd, root: root, filter: wf, ch: make(chan struct{}, 1),
+		done: make(chan struct{})}
+	pn.state = pn.snapshot()
+	go pn.run(interval)
+	return pn
+}
+
+// snapshot возвращает размер и время изменения всех не скрытых и не
+// исключённых файлов. Для директорий сохраняется только их наличие, время
+// изменения директории меняется и при создании исключённого файла.
+func (pn *pollNotifier) snapshot() map[string]string {
+	state := map[string]string{}
+	pn.filter.walk(pn.fsd, pn.root, func(p string, d fs.DirEntry) {
+		if d.IsDir() {
+			state[p] = "dir"
+		} else if info, err := d.Info(); err == nil {
+			state[p] = fmt.Sprint(info.Size(), info.ModTime().UnixNano())
+		}
+	})
+	return state
+}
+
+func (pn *pollNotifier) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pn.done:
+			return
+		case <-ticker.C:
+		}
+		state := pn.snapshot()
+		if !equalState(pn.state, state) {
+			pn.state = state
+			notify(pn.ch)
+		}
+	}
+}
+
+// equalState сравнивает два снимка файловой системы
+func equalState(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (pn *pollNotifier) add(dir string) error     { return nil }
+func (pn *pollNotifier) changes() <-chan struct{} { return pn.ch }
+func (pn *pollNotifier) close() error             { close(pn.done); return nil }
+
+// notify отправляет уведомление не блокируясь, если предыдущее уведомление
+// ещё не получено
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// watchDirs возвращает все не скрытые и не исключённые фильтром wf
+// директории начиная с root
+func watchDirs(fsd fs.FS, root string, wf *watchFilter) []string {
+	dirs := make([]string, 0)
+	wf.walk(fsd, root, func(p string, d fs.DirEntry) {
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		}
+	})
+	return dirs
+}
+
+// WatchOptions параметры наблюдения за изменениями
+type WatchOptions struct {
+	debounce time.Duration                  // пауза после последнего изменения
+	interval time.Duration                  // интервал опроса без inotify
+	events   bool                           // выводить события вместо отчёта
+	write    func(io.Writer, []Todos) error // формат отчёта
+	poll     bool                           // не использовать inotify
+	exclude  []string                       // файлы, изменения которых не учитываются
+}
+
+// DefaultWatchOptions возвращает параметры наблюдения по умолчанию
+func DefaultWatchOptions() WatchOptions {
+	return WatchOptions{debounce: 300 * time.Millisecond, interval: 2 * time.Second,
+		write: WriteOrg}
+}
+
+// todoKey возвращает ключ блока для сравнения результатов поиска
+func todoKey(td Todos) string {
+	return td.position + "\x00" + td.tag + "\x00" + strings.Join(td.lines, "\n")
+}
+
+// writeEvents выводит блоки которые появились, строкой «+ », и пропали,
+// строкой «- », по сравнению с предыдущим результатом поиска. Строки
+// форматируются как сообщения компилятора.
+func writeEvents(w io.Writer, prev, cur []Todos) error {
+	wd, _ := os.Getwd()
+	old := map[string]bool{}
+	for _, td := range prev {
+		old[todoKey(td)] = true
+	}
+	now := map[string]bool{}
+	bw := bufio.NewWriter(w)
+	for _, td := range cur {
+		now[todoKey(td)] = true
+		if !old[todoKey(td)] {
+			bw.WriteString("+ " + td.quickfix(wd) + "\n")
+		}
+	}
+	for _, td := range prev {
+		if !now[todoKey(td)] {
+			bw.WriteString("- " + td.quickfix(wd) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// Watch выполняет поиск блоков, выводит результат и повторяет поиск при
+// каждом изменении файлов в директории dir до закрытия канала stop. За
+// изменениями следит inotify, если он недоступен, файлы периодически
+// опрашиваются. Изменения следующие друг за другом быстрее opt.debounce
+// объединяются. Новые директории, в том числе новые проекты, добавляются в
+// наблюдение после каждого поиска. Изменения файлов wopt.exclude, например,
+// файла отчёта, и файлов исключённых правилами .gitignore и .todoignore не
+// учитываются.
+//
+// Если wopt.events равно false, после каждого поиска выводится полный отчёт,
+// иначе только события появления и исчезновения блоков. Ошибки поиска
+// выводятся в errw.
+func Watch(fsd fs.FS, base string, dir string, opt ScanOptions, wopt WatchOptions,
+	w io.Writer, errw io.Writer, stop <-chan struct{}) error {
+	wf := newWatchFilter(wopt.exclude)
+	var n notifier
+	if !wopt.poll {
+		in, err := newInotify(base, wf)
+		if err == nil {
+			n = in
+		}
+	}
+	if n == nil {
+		n = newPollNotifier(fsd, dir, wopt.interval, wf)
+	}
+	defer n.close()
+
+	// изменившиеся файлы разбираются заново, остальные берутся из кэша
+	if opt.cache == nil {
+		opt.cache = newCache("")
+	}
+
+	var prev []Todos
+	for {
+		for _, d := range watchDirs(fsd, dir, wf) {
+			if err := n.add(d); err != nil {
+				fmt.Fprintln(errw, err)
+			}
+		}
+		todos, errs := Scan(fsd, base, dir, opt)
+		for _, err := range errs {
+			fmt.Fprintln(errw, err)
+		}
+		var err error
+		if wopt.events {
+			err = writeEvents(w, prev, todos)
+		} else {
+			err = wopt.write(w, todos)
+		}
+		if err != nil {
+			return err
+		}
+		prev = todos
+
+		select {
+		case <-stop:
+			return nil
+		case <-n.changes():
+		}
+		// ждём паузы в изменениях
+		for wait := true; wait; {
+			select {
+			case <-stop:
+				return nil
+			case <-n.changes():
+			case <-time.After(wopt.debounce):
+				wait = false
+			}
+		}
+	}
+}
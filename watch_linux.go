@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask события inotify, после которых нужен повторный поиск
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MODIFY | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF
+
+// inotify наблюдает за директориями с помощью inotify ядра Linux
+type inotify struct {
+	base   string       // префикс пути директорий в файловой системе
+	fd     int          // дескриптор inotify
+	file   *os.File     // файл дескриптора для чтения через poller
+	filter *watchFilter // изменения, которые не учитываются
+	mu     sync.Mutex   // защищает dirs
+	dirs   map[int32]string
+	ch     chan struct{}
+}
+
+// newInotify возвращает наблюдателя inotify. Пути директорий передаваемые в
+// add дополняются префиксом base. События файлов исключённых фильтром wf
+// пропускаются.
+func newInotify(base string, wf *watchFilter) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	in := &inotify{base: base, fd: fd, file: os.NewFile(uintptr(fd), "inotify"),
+		filter: wf, dirs: map[int32]string{}, ch: make(chan struct{}, 1)}
+	go in.run()
+	return in, nil
+}
+
+func (in *inotify) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			return
+		}
+		changed := false
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + syscall.SizeofInotifyEvent
+			off = start + int(ev.Len)
+			name := strings.TrimRight(string(buf[start:off]), "\x00")
+			in.mu.Lock()
+			dir, ok := in.dirs[ev.Wd]
+			in.mu.Unlock()
+			if !ok || name == "" || !in.filter.skip(path.Join(dir, name), ev.Mask&syscall.IN_ISDIR != 0) {
+				changed = true
+			}
+		}
+		if changed {
+			notify(in.ch)
+		}
+	}
+}
+
+func (in *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, filepath.Join(in.base, dir), inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	in.mu.Lock()
+	in.dirs[int32(wd)] = dir
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *inotify) changes() <-chan struct{} { return in.ch }
+func (in *inotify) close() error             { return in.file.Close() }
@@ -0,0 +1,15 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "errors"
+
+// newInotify на системах отличных от Linux inotify недоступен, используется
+// опрос файлов
+func newInotify(base string, wf *watchFilter) (notifier, error) {
+	return nil, errors.New("inotify недоступен")
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Всратослав Бурый
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer буфер безопасный для записи из другой горутины
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.String()
+}
+
+// waitFor ждёт появления строки в буфере
+func waitFor(t *testing.T, sb *syncBuffer, str string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if strings.Contains(sb.String(), str) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("наблюдение: не найдено %q в выводе:\n%s", str, sb.String())
+}
+
+// Test_Watch тестирует режим наблюдения с выводом событий. После изменения
+// файла проекта должно появиться событие нового блока и событие исчезнувшего
+// блока, а новый проект созданный после запуска наблюдения должен быть
+// найден. Проверка выполняется с inotify и с опросом файлов.
+func Test_Watch(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		write := func(name, data string) {
+			t.Helper()
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		write("hello/go.mod", "module hello\n")
+		write("hello/main.go", "// TODO: first\n")
+
+		wopt := DefaultWatchOptions()
+		wopt.events, wopt.poll = true, poll
+		wopt.debounce, wopt.interval = 50*time.Millisecond, 50*time.Millisecond
+		opt := DefaultScanOptions()
+		opt.markers = []string{"go.mod"}
+
+		var out syncBuffer
+		stop := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- Watch(os.DirFS("/"), "/", dir[1:], opt, wopt, &out, io.Discard, stop)
+		}()
+
+		waitFor(t, &out, "+ ")
+		waitFor(t, &out, "TODO: first")
+		write("hello/main.go", "// FIXME: second\n")
+		waitFor(t, &out, "FIXME: second")
+		waitFor(t, &out, "- ")
+		write("world/go.mod", "module world\n")
+		write("world/main.go", "// BUG: third\n")
+		waitFor(t, &out, "BUG: third")
+
+		close(stop)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test_WatchExclude тестирует, что запись файла отчёта внутри директории
+// наблюдения, изменения файлов исключённых .gitignore и скрытых файлов,
+// например, файлов подкачки редактора, не вызывают повторный поиск, а
+// изменения файлов проекта вызывают. Проверка выполняется с inotify
+// и с опросом файлов.
+func Test_WatchExclude(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		write := func(name, data string) {
+			t.Helper()
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		write("hello/go.mod", "module hello\n")
+		write("hello/main.go", "// TODO: first\n")
+		write(".gitignore", "build/\n")
+		write("build/gen.go", "// TODO: generated\n")
+
+		var mu sync.Mutex
+		scans := 0
+		count := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return scans
+		}
+		wopt := DefaultWatchOptions()
+		wopt.poll = poll
+		wopt.debounce, wopt.interval = 50*time.Millisecond, 50*time.Millisecond
+		wopt.exclude = []string{dir[1:] + "/todo.org"}
+		wopt.write = func(w io.Writer, todos []Todos) error {
+			mu.Lock()
+			scans++
+			mu.Unlock()
+			return writeFile(filepath.Join(dir, "todo.org"), todos, WriteOrg)
+		}
+		opt := DefaultScanOptions()
+		opt.markers = []string{"go.mod"}
+
+		stop := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- Watch(os.DirFS("/"), "/", dir[1:], opt, wopt, io.Discard, io.Discard, stop)
+		}()
+
+		time.Sleep(500 * time.Millisecond)
+		write("build/gen.go", "// TODO: regenerated\n")
+		write("hello/.main.go.swp", "swap")
+		time.Sleep(500 * time.Millisecond)
+		if n := count(); n != 1 {
+			t.Errorf("наблюдение poll=%v: требуется 1 поиск, имеется: %d", poll, n)
+		}
+		write("hello/main.go", "// TODO: second\n")
+		for deadline := time.Now().Add(5 * time.Second); count() < 2 && time.Now().Before(deadline); {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if n := count(); n != 2 {
+			t.Errorf("наблюдение poll=%v: требуется 2 поиска, имеется: %d", poll, n)
+		}
+
+		close(stop)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}